@@ -0,0 +1,411 @@
+// Package aba generates Australian Direct Entry files, known as ABA or
+// Cemtex files, from TXN payment batches so the same payments can be
+// lodged through BECS.
+package aba
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/17twenty/txn"
+	"github.com/17twenty/txn/bsb"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	dateFormat = "020106"
+	lineLength = 120
+	lineEnding = "\r\n"
+	totalBSB   = "999-999" // BSB of the file total record
+	maxCents   = 9999999999
+	maxCount   = 999999
+)
+
+var (
+	ErrNotPayment         = errors.New("aba: Only SP payment batches can be lodged")
+	ErrBadUserID          = errors.New("aba: User ID must be the 6 digit number allocated by APCA")
+	ErrBadAccount         = errors.New("aba: Account number must be 1 to 9 digits or hyphens and not all zeros")
+	ErrBadAmount          = errors.New("aba: Amount must be whole cents no more than 99999999.99")
+	ErrBadTransactionCode = errors.New("aba: Transaction code must be registered as a debit or credit")
+	ErrMissingField       = errors.New("aba: Mandatory field is blank")
+	ErrUnknownBank        = errors.New("aba: No financial institution for the BSB")
+	ErrTooManyRecords     = errors.New("aba: Too many records for one file")
+)
+
+var (
+	accountRegEx = regexp.MustCompile(`^[\d-]{1,9}$`)
+	userIDRegEx  = regexp.MustCompile(`^\d{6}$`)
+)
+
+// Field lengths of the descriptive and detail records
+const (
+	bankLength        = 3
+	userNameLength    = 26
+	descriptionLength = 12
+	titleLength       = 32
+	referenceLength   = 18
+	remitterLength    = 16
+)
+
+// Encoder writes ABA files to an output stream
+type Encoder struct {
+	// UserName is the user preferred specification, the AccountName of the
+	// BatchHeader if empty
+	UserName string
+	// UserID is the 6 digit Direct Entry user identification number
+	// allocated by APCA
+	UserID string
+	// Description of the entries in the file, e.g. PAYROLL
+	Description string
+	// LodgementReference is shown on the statement of the payee for records
+	// without a Description
+	LodgementReference string
+	// Remitter is shown to each payee, UserName if empty
+	Remitter string
+	// Bank is the mnemonic of the user's financial institution, taken from
+	// the BSB of the BatchHeader if empty
+	Bank string
+	// ProcessingDate of the file, the BatchHeader TransactionDate if zero
+	ProcessingDate time.Time
+	// Balance appends a record against the account of the BatchHeader that
+	// offsets the others, for institutions requiring a self-balancing file
+	Balance bool
+	w       *bufio.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// ValidationError is returned by Encode when a batch can't be lodged. It
+// holds every SeverityError Finding of the Validate report.
+type ValidationError struct {
+	Findings []txn.Finding
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		msgs[i] = f.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Findings))
+	for i, f := range e.Findings {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// detail is a Record as it will be written to a type 1 line
+type detail struct {
+	bsb, account, title string
+	code                string
+	debit               bool
+	cents               int64
+	reference           string
+}
+
+// Encode writes b as a complete ABA file: a type 0 descriptive record, a
+// type 1 detail record per Record and a type 7 file total record. The BSB
+// and account of the BatchHeader are the trace account returned items go
+// to. Nothing is written if Validate reports an error.
+func (e *Encoder) Encode(b *txn.Batch) error {
+	report := e.Validate(b)
+	if errs := report.Errors(); len(errs) > 0 {
+		return &ValidationError{Findings: errs}
+	}
+
+	h := &b.BatchHeader
+	details := make([]detail, 0, len(b.Records)+1)
+	var credits, debits int64
+	for k := range b.Records {
+		d := e.detail(&b.Records[k])
+		if d.debit {
+			debits += d.cents
+		} else {
+			credits += d.cents
+		}
+		details = append(details, d)
+	}
+	if net := credits - debits; e.Balance && net != 0 {
+		d := detail{bsb: h.BSBNumber, account: h.AccountNumber, title: h.AccountName, code: txn.DefaultTransactionCode(txn.Debit), debit: true, cents: net, reference: e.Description}
+		if net < 0 {
+			d.code, d.debit, d.cents = txn.DefaultTransactionCode(txn.Credit), false, -net
+		}
+		if d.debit {
+			debits += d.cents
+		} else {
+			credits += d.cents
+		}
+		details = append(details, d)
+	}
+
+	line := newLine()
+	line.text("0", 1).text("", 17).text("01", 2).text(e.bank(h), bankLength).text("", 7)
+	line.text(e.userName(h), userNameLength).number(e.UserID, 6).text(e.Description, descriptionLength)
+	line.text(e.processingDate(h).Format(dateFormat), 6)
+	e.write(line)
+
+	for _, d := range details {
+		line := newLine()
+		line.text("1", 1).text(d.bsb, 7).number(d.account, 9).text("", 1).text(d.code, 2).cents(d.cents, 10)
+		line.text(d.title, titleLength).text(d.reference, referenceLength)
+		line.text(h.BSBNumber, 7).number(h.AccountNumber, 9).text(e.remitter(h), remitterLength).cents(0, 8)
+		e.write(line)
+	}
+
+	net := credits - debits
+	if net < 0 {
+		net = -net
+	}
+	line = newLine()
+	line.text("7", 1).text(totalBSB, 7).text("", 12).cents(net, 10).cents(credits, 10).cents(debits, 10)
+	line.text("", 24).cents(int64(len(details)), 6)
+	e.write(line)
+	return e.w.Flush()
+}
+
+// Validate checks b and the options of e against the Direct Entry rules
+// without writing anything, reporting every problem Encode would stop at
+// along with values that will be truncated to fit.
+func (e *Encoder) Validate(b *txn.Batch) txn.ValidationReport {
+	v := validation{}
+	h := &b.BatchHeader
+
+	if b.BatchTrailer.BatchType != txn.BatchPAY {
+		v.fail(-1, "BatchTrailer.BatchType", fmt.Errorf("%w, got %q", ErrNotPayment, b.BatchTrailer.BatchType))
+	}
+	if !userIDRegEx.MatchString(e.UserID) {
+		v.fail(-1, "UserID", ErrBadUserID)
+	}
+	v.text(-1, "UserName", e.userName(h), userNameLength)
+	v.text(-1, "Description", e.Description, descriptionLength)
+	v.text(-1, "Remitter", e.remitter(h), remitterLength)
+	if e.bank(h) == "" {
+		v.fail(-1, "Bank", ErrUnknownBank)
+	}
+	if e.processingDate(h).IsZero() {
+		v.fail(-1, "ProcessingDate", ErrMissingField)
+	}
+	v.account(-1, "BatchHeader", h.BSBNumber, h.AccountNumber)
+	if e.Balance {
+		v.text(-1, "BatchHeader.AccountName", h.AccountName, titleLength)
+	}
+
+	if len(b.Records) == 0 {
+		v.warn(-1, "", txn.ErrEmptyBatch)
+	}
+	if len(b.Records) >= maxCount {
+		v.fail(-1, "", ErrTooManyRecords)
+	}
+	var credits, debits decimal.Decimal
+	for k := range b.Records {
+		r := &b.Records[k]
+		v.account(k, "Record", r.BSBNumber, r.AccountNumber)
+		v.text(k, "Record.AccountName", r.AccountName, titleLength)
+		v.text(k, "Record.Description", e.reference(r), referenceLength)
+		v.amount(k, "Record.Amount", r.Amount)
+		if r.Amount.IsZero() {
+			v.warn(k, "Record.Amount", txn.ErrZeroAmount)
+		}
+
+		switch r.Indicator {
+		case txn.Debit:
+			debits = debits.Add(r.Amount)
+		case txn.Credit:
+			credits = credits.Add(r.Amount)
+		default:
+			v.fail(k, "Record.Indicator", fmt.Errorf("%w, got %q", txn.ErrUnknownIndicator, r.Indicator))
+			continue
+		}
+		// Direct Entry codes each post in one direction, so codes registered
+		// for either can't be lodged
+		code := e.code(r)
+		if tc, ok := txn.LookupTransactionCode(code); !ok || tc.Indicator == "" {
+			v.fail(k, "Record.TransactionCode", fmt.Errorf("%w, got %q", ErrBadTransactionCode, code))
+		} else if tc.Indicator != r.Indicator {
+			v.fail(k, "Record.TransactionCode", txn.ErrWrongDirection)
+		}
+	}
+	v.amount(-1, "Total", credits.Sub(debits).Abs())
+	v.amount(-1, "TotalCreditAmount", credits)
+	v.amount(-1, "TotalDebitAmount", debits)
+	return v.report
+}
+
+// validation accumulates the findings for a single batch
+type validation struct {
+	report txn.ValidationReport
+}
+
+func (v *validation) add(s txn.Severity, record int, field string, err error) {
+	v.report.Findings = append(v.report.Findings, txn.Finding{
+		Severity: s,
+		Record:   record,
+		Field:    field,
+		Err:      err,
+	})
+}
+
+func (v *validation) fail(record int, field string, err error) {
+	v.add(txn.SeverityError, record, field, err)
+}
+
+func (v *validation) warn(record int, field string, err error) {
+	v.add(txn.SeverityWarning, record, field, err)
+}
+
+// text checks a mandatory field is present, warning if it will be cut
+func (v *validation) text(record int, field, s string, length int) {
+	switch s = strings.TrimSpace(s); {
+	case s == "":
+		v.fail(record, field, ErrMissingField)
+	case len(s) > length:
+		v.warn(record, field, fmt.Errorf("%w of %d characters: %q", txn.ErrTruncated, length, s))
+	}
+}
+
+// account checks a BSB and the 9 character account field
+func (v *validation) account(record int, recordType, bsbNumber, accountNumber string) {
+	if !bsb.Valid(bsbNumber) {
+		v.fail(record, recordType+".BSBNumber", txn.ErrBadBSB)
+	}
+	if !accountRegEx.MatchString(accountNumber) || strings.Trim(accountNumber, "0-") == "" {
+		v.fail(record, recordType+".AccountNumber", ErrBadAccount)
+	}
+}
+
+// amount checks d can be written as a 10 digit count of cents
+func (v *validation) amount(record int, field string, d decimal.Decimal) {
+	cents := d.Shift(2)
+	if d.Sign() < 0 || !cents.IsInteger() || cents.GreaterThan(decimal.NewFromInt(maxCents)) {
+		v.fail(record, field, ErrBadAmount)
+	}
+}
+
+// detail converts a validated Record
+func (e *Encoder) detail(r *txn.Record) detail {
+	return detail{
+		bsb:       r.BSBNumber,
+		account:   r.AccountNumber,
+		title:     r.AccountName,
+		code:      e.code(r),
+		debit:     r.Indicator == txn.Debit,
+		cents:     r.Amount.Shift(2).IntPart(),
+		reference: e.reference(r),
+	}
+}
+
+// code returns the transaction code of r, the default for its direction if
+// blank
+func (e *Encoder) code(r *txn.Record) string {
+	if r.TransactionCode == "" {
+		return txn.DefaultTransactionCode(r.Indicator)
+	}
+	return r.TransactionCode
+}
+
+// reference returns the lodgement reference of r
+func (e *Encoder) reference(r *txn.Record) string {
+	if s := strings.TrimSpace(r.Description); s != "" {
+		return s
+	}
+	return e.LodgementReference
+}
+
+func (e *Encoder) userName(h *txn.BatchHeader) string {
+	if e.UserName == "" {
+		return h.AccountName
+	}
+	return e.UserName
+}
+
+func (e *Encoder) remitter(h *txn.BatchHeader) string {
+	if e.Remitter == "" {
+		return e.userName(h)
+	}
+	return e.Remitter
+}
+
+func (e *Encoder) bank(h *txn.BatchHeader) string {
+	if e.Bank != "" {
+		return e.Bank
+	}
+	m, _ := bsb.Bank(h.BSBNumber)
+	return m
+}
+
+func (e *Encoder) processingDate(h *txn.BatchHeader) time.Time {
+	if e.ProcessingDate.IsZero() {
+		return h.TransactionDate
+	}
+	return e.ProcessingDate
+}
+
+func (e *Encoder) write(l *line) {
+	e.w.WriteString(l.String())
+	e.w.WriteString(lineEnding)
+}
+
+// line builds a fixed width record, padding it out to lineLength
+type line struct {
+	strings.Builder
+}
+
+func newLine() *line {
+	l := new(line)
+	l.Grow(lineLength)
+	return l
+}
+
+func (l *line) String() string {
+	return l.Builder.String() + strings.Repeat(" ", lineLength-l.Len())
+}
+
+// text writes s left justified and blank filled, cut to length
+func (l *line) text(s string, length int) *line {
+	s = clean(s)
+	if len(s) > length {
+		s = s[:length]
+	}
+	l.WriteString(s)
+	l.WriteString(strings.Repeat(" ", length-len(s)))
+	return l
+}
+
+// number writes s right justified and blank filled
+func (l *line) number(s string, length int) *line {
+	s = clean(s)
+	if len(s) > length {
+		s = s[len(s)-length:]
+	}
+	l.WriteString(strings.Repeat(" ", length-len(s)))
+	l.WriteString(s)
+	return l
+}
+
+// cents writes n right justified and zero filled
+func (l *line) cents(n int64, length int) *line {
+	fmt.Fprintf(l, "%0*d", length, n)
+	return l
+}
+
+// clean trims s and replaces anything outside of printable ASCII, which is
+// all the BECS character set allows
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return ' '
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
@@ -0,0 +1,140 @@
+package aba
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/17twenty/txn"
+	"github.com/shopspring/decimal"
+)
+
+func payments() *txn.Batch {
+	date := time.Date(2017, 1, 23, 0, 0, 0, 0, time.UTC)
+	return &txn.Batch{
+		BatchHeader: txn.BatchHeader{
+			BSBNumber:       "182-222",
+			AccountNumber:   "117867898",
+			AccountName:     "DEMO ACCOUNT NUMBER 2",
+			TransactionDate: date,
+		},
+		Records: []txn.Record{
+			{BSBNumber: "062-000", AccountNumber: "12345678", AccountName: "J Citizen", Amount: decimal.RequireFromString("1234.56"), Indicator: txn.Credit, TransactionCode: "53", Description: "Wages January"},
+			{BSBNumber: "033-000", AccountNumber: "1-2345", AccountName: "Smith & Sons Pty Ltd", Amount: decimal.RequireFromString("100"), Indicator: txn.Credit},
+			{BSBNumber: "012-003", AccountNumber: "987654321", AccountName: "Refund Pty Ltd", Amount: decimal.RequireFromString("34.56"), Indicator: txn.Debit, Description: "Refund"},
+		},
+		BatchTrailer: txn.BatchTrailer{BatchType: txn.BatchPAY},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.UserID, e.Description, e.LodgementReference = "301500", "PAYROLL", "INV 42"
+	if err := e.Encode(payments()); err != nil {
+		t.Fatal("error encoding", err)
+	}
+
+	lines := strings.Split(buf.String(), "\r\n")
+	expected := []string{
+		"0                 01MBL       DEMO ACCOUNT NUMBER 2     301500PAYROLL     230117                                        ",
+		"1062-000 12345678 530000123456J Citizen                       Wages January     182-222117867898DEMO ACCOUNT NUM00000000",
+		"1033-000   1-2345 500000010000Smith & Sons Pty Ltd            INV 42            182-222117867898DEMO ACCOUNT NUM00000000",
+		"1012-003987654321 130000003456Refund Pty Ltd                  Refund            182-222117867898DEMO ACCOUNT NUM00000000",
+		"7999-999            000013000000001334560000003456                        000003                                        ",
+		"",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Failure - expected %d lines but got %q", len(expected)-1, lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Fatalf("Failure - line %d expected\n%q\nbut got\n%q", i+1, expected[i], lines[i])
+		}
+		if lines[i] != "" && len(lines[i]) != lineLength {
+			t.Fatal("Expected 120 characters but got", len(lines[i]))
+		}
+	}
+}
+
+func TestEncodeBalanced(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.UserID, e.Description, e.LodgementReference, e.Remitter, e.Balance = "301500", "PAYROLL", "INV 42", "DEMO", true
+	if err := e.Encode(payments()); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	lines := strings.Split(buf.String(), "\r\n")
+	if lines[4] != "1182-222117867898 130000130000DEMO ACCOUNT NUMBER 2           PAYROLL           182-222117867898DEMO            00000000" {
+		t.Fatalf("Failure - unexpected balancing record %q", lines[4])
+	}
+	if !strings.HasPrefix(lines[5], "7999-999            000000000000001334560000133456                        000004") {
+		t.Fatalf("Failure - unexpected file total record %q", lines[5])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b := payments()
+	b.BatchTrailer.BatchType = txn.BatchTXN
+	b.BatchHeader.BSBNumber = "182222"
+	b.Records[0].AccountNumber = "1234567890"
+	b.Records[1].TransactionCode = "13"
+	b.Records[1].AccountName = "A payee name much longer than thirty two characters"
+	b.Records[2].Amount = decimal.RequireFromString("0.001")
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.UserID = "APCA"
+	report := e.Validate(b)
+
+	expected := []struct {
+		record int
+		field  string
+		err    error
+	}{
+		{-1, "BatchTrailer.BatchType", ErrNotPayment},
+		{-1, "UserID", ErrBadUserID},
+		{-1, "Description", ErrMissingField},
+		{-1, "Bank", ErrUnknownBank},
+		{-1, "BatchHeader.BSBNumber", txn.ErrBadBSB},
+		{0, "Record.AccountNumber", ErrBadAccount},
+		{1, "Record.Description", ErrMissingField},
+		{1, "Record.TransactionCode", txn.ErrWrongDirection},
+		{2, "Record.Amount", ErrBadAmount},
+		{-1, "Total", ErrBadAmount},
+		{-1, "TotalDebitAmount", ErrBadAmount},
+	}
+	errs := report.Errors()
+	if len(errs) != len(expected) {
+		t.Fatal("Expected", len(expected), "errors but got", errs)
+	}
+	for i, want := range expected {
+		if f := errs[i]; f.Record != want.record || f.Field != want.field || !errors.Is(f.Err, want.err) {
+			t.Fatalf("Failure - expected %v on %s of record %d but got %v", want.err, want.field, want.record, f)
+		}
+	}
+	// The default remitter is the 21 character name of the account
+	w := report.Warnings()
+	if len(w) != 2 || w[0].Field != "Remitter" || w[1].Field != "Record.AccountName" || !errors.Is(w[1].Err, txn.ErrTruncated) {
+		t.Fatal("Expected a truncated remitter and account name but got", w)
+	}
+
+	err := e.Encode(b)
+	if !errors.Is(err, ErrNotPayment) || buf.Len() != 0 {
+		t.Fatal("Expected '", ErrNotPayment, "' and nothing written but got", err)
+	}
+
+	// Only codes registered for one direction can be lodged
+	b = payments()
+	b.Records[0].TransactionCode = "39"
+	txn.RegisterTransactionCode(txn.TransactionCode{Code: "98", Description: "Either way"})
+	b.Records[1].TransactionCode = "98"
+	e.UserID, e.Description, e.LodgementReference = "301500", "PAYROLL", "INV 42"
+	report = e.Validate(b)
+	errs = report.Errors()
+	if len(errs) != 2 || errs[0].Record != 0 || !errors.Is(errs[0].Err, ErrBadTransactionCode) || errs[1].Record != 1 || !errors.Is(errs[1].Err, ErrBadTransactionCode) {
+		t.Fatal("Expected '", ErrBadTransactionCode, "' for records 0 and 1 but got", errs)
+	}
+}
@@ -0,0 +1,243 @@
+// Package bsb resolves Australian Bank State Branch numbers to the bank and
+// branch they belong to, using directories in the public BSB CSV format.
+//
+// No directory is built in as it changes every month. Download the current
+// one published by APCA and load it with Open or Parse, reloading it to
+// pick up changes.
+package bsb
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrBadDirectory = errors.New("bsb: Directory CSV is missing the BSB column")
+	ErrBadBSB       = errors.New("bsb: BSB must be in the format 000-000")
+
+	bsbRegEx = regexp.MustCompile(`^\d{3}-\d{3}$`)
+)
+
+// Branch is a single entry of the BSB directory
+type Branch struct {
+	BSB      string // e.g. 182-222
+	Mnemonic string // bank mnemonic e.g. MBL
+	Name     string // branch name
+	Address  string
+	Suburb   string
+	State    string // e.g. NSW
+	Postcode string
+	Flags    Flags
+	Closed   bool // no longer accepts payments
+}
+
+// Flags are the payment systems a branch takes part in, written as a
+// combination of P, E and H in the directory
+type Flags struct {
+	Paper      bool // P - cheques and other paper clearing
+	Electronic bool // E - direct entry
+	HighValue  bool // H - real time high value payments
+}
+
+// ParseFlags decodes the flags column of the directory, ignoring anything
+// other than P, E and H
+func ParseFlags(s string) Flags {
+	s = strings.ToUpper(s)
+	return Flags{
+		Paper:      strings.Contains(s, "P"),
+		Electronic: strings.Contains(s, "E"),
+		HighValue:  strings.Contains(s, "H"),
+	}
+}
+
+func (f Flags) String() string {
+	var s []byte
+	if f.Paper {
+		s = append(s, 'P')
+	}
+	if f.Electronic {
+		s = append(s, 'E')
+	}
+	if f.HighValue {
+		s = append(s, 'H')
+	}
+	return string(s)
+}
+
+// None reports whether the branch takes part in no payment system
+func (f Flags) None() bool {
+	return !f.Paper && !f.Electronic && !f.HighValue
+}
+
+// Directory of branches keyed by BSB
+type Directory struct {
+	branches map[string]Branch
+}
+
+// columns is the fixed order of the columns in the published directory,
+// which has no header row
+var columns = []string{"bsb", "mnemonic", "name", "address", "suburb", "state", "postcode", "flags"}
+
+// Parse reads a directory in the public BSB CSV format, one row per branch
+// with the columns BSB, mnemonic, name, address, suburb, state, postcode
+// and flags. If the first row isn't a branch it is taken as a header naming
+// the columns instead. A branch is closed when its payment system flags are
+// empty or it is marked Closed.
+func Parse(r io.Reader) (*Directory, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	row, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{}
+	if bsbRegEx.MatchString(strings.TrimSpace(row[0])) {
+		for i, name := range columns {
+			cols[name] = i
+		}
+	} else {
+		for i, name := range row {
+			cols[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := cols["bsb"]; !ok {
+			return nil, ErrBadDirectory
+		}
+		row, err = cr.Read()
+	}
+	col := func(row []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	d := &Directory{branches: map[string]Branch{}}
+	for ; err != io.EOF; row, err = cr.Read() {
+		if err != nil {
+			return nil, err
+		}
+		flags := col(row, "flags")
+		b := Branch{
+			BSB:      col(row, "bsb"),
+			Mnemonic: col(row, "mnemonic"),
+			Name:     col(row, "name"),
+			Address:  col(row, "address"),
+			Suburb:   col(row, "suburb"),
+			State:    col(row, "state"),
+			Postcode: col(row, "postcode"),
+		}
+		if !strings.EqualFold(flags, "closed") {
+			b.Flags = ParseFlags(flags)
+		}
+		b.Closed = b.Flags.None()
+		if !bsbRegEx.MatchString(b.BSB) {
+			line, _ := cr.FieldPos(0)
+			return nil, &csv.ParseError{StartLine: line, Line: line, Err: ErrBadBSB}
+		}
+		d.branches[b.BSB] = b
+	}
+	return d, nil
+}
+
+// Open reads a directory from a local file in the public BSB CSV format
+func Open(name string) (*Directory, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Lookup returns the branch for bsb, false if the directory doesn't list it
+func (d *Directory) Lookup(bsb string) (Branch, bool) {
+	b, ok := d.branches[bsb]
+	return b, ok
+}
+
+// Len is the number of branches in the directory
+func (d *Directory) Len() int {
+	return len(d.branches)
+}
+
+// Banks maps the first two digits of a BSB to the mnemonic of the bank they
+// are allocated to. Only the common prefixes are listed, so a BSB missing
+// from it may still be valid, only a Directory can tell. Add to it as new
+// prefixes are allocated.
+var Banks = map[string]string{
+	"01": "ANZ",
+	"03": "WBC",
+	"04": "WBC",
+	"06": "CBA",
+	"08": "NAB",
+	"09": "RBA",
+	"10": "BSA",
+	"11": "STG",
+	"12": "BQL",
+	"18": "MBL",
+	"19": "BOM",
+	"21": "CMB",
+	"22": "BNP",
+	"23": "BAL",
+	"24": "CTI",
+	"29": "BOT",
+	"30": "BWA",
+	"33": "STG",
+	"34": "HBA",
+	"35": "BOC",
+	"40": "CST",
+	"41": "DBA",
+	"48": "MET",
+	"55": "BML",
+	"57": "ASL",
+	"61": "ADL",
+	"63": "BBL",
+	"73": "WBC",
+	"76": "CBA",
+	"78": "NAB",
+	"80": "CRU",
+	"90": "APO",
+	"92": "ING",
+	"93": "AMP",
+}
+
+// States maps the third digit of a BSB to the state it was allocated in
+var States = map[byte]string{
+	'2': "NSW",
+	'3': "VIC",
+	'4': "QLD",
+	'5': "SA",
+	'6': "WA",
+	'7': "TAS",
+}
+
+// Valid reports whether bsb is in the format 000-000. It says nothing of
+// whether the BSB is allocated, only a Directory can tell.
+func Valid(bsb string) bool {
+	return bsbRegEx.MatchString(bsb)
+}
+
+// Bank returns the mnemonic of the bank the prefix of bsb is allocated to
+func Bank(bsb string) (string, bool) {
+	if !bsbRegEx.MatchString(bsb) {
+		return "", false
+	}
+	m, ok := Banks[bsb[:2]]
+	return m, ok
+}
+
+// State returns the state encoded in the third digit of bsb, false for the
+// digits used by national or special purpose BSBs
+func State(bsb string) (string, bool) {
+	if !bsbRegEx.MatchString(bsb) {
+		return "", false
+	}
+	s, ok := States[bsb[2]]
+	return s, ok
+}
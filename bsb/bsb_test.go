@@ -0,0 +1,87 @@
+package bsb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	d, err := Parse(strings.NewReader("BSB,Mnemonic,Name,State,Flags\n012-003,ANZ,Sydney,NSW,PEH\n"))
+	if err != nil {
+		t.Fatal("error parsing", err)
+	}
+	if b, ok := d.Lookup("012-003"); !ok || b.Name != "Sydney" {
+		t.Fatalf("Failure - expected the Sydney branch but got %+v", b)
+	}
+
+	if _, err = Parse(strings.NewReader("Name,State\nSydney,NSW\n")); err != ErrBadDirectory {
+		t.Fatal("Expected '", ErrBadDirectory, "' but got", err)
+	}
+	if _, err = Parse(strings.NewReader("BSB\n012003\n")); !errors.Is(err, ErrBadBSB) {
+		t.Fatal("Expected '", ErrBadBSB, "' but got", err)
+	}
+	if _, ok := d.Lookup("182-222"); ok {
+		t.Fatal("Expected 182-222 to be missing")
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	if m, ok := Bank("182-222"); !ok || m != "MBL" {
+		t.Fatal("Expected 'MBL' but got", m)
+	}
+	if s, ok := State("183-334"); !ok || s != "VIC" {
+		t.Fatal("Expected 'VIC' but got", s)
+	}
+	if _, ok := Bank("999-999"); ok {
+		t.Fatal("Expected 999 to be unallocated")
+	}
+	if !Valid("704-235") || Valid("704235") || Valid(" 704-235") {
+		t.Fatal("Expected only 000-000 to be valid")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	d, err := Open("testdata/directory.csv")
+	if err != nil {
+		t.Fatal("error opening directory", err)
+	}
+	if d.Len() != 3 {
+		t.Fatal("Expected 3 branches but got", d.Len())
+	}
+	b, _ := d.Lookup("012-003")
+	if b.Address != "1 Sample Street" || b.Suburb != "Sydney" || b.Postcode != "2000" || b.Flags.String() != "PEH" {
+		t.Fatalf("Failure - expected every column but got %+v", b)
+	}
+	if b, _ := d.Lookup("063-000"); b.Closed || !b.Flags.Electronic || b.Flags.Paper {
+		t.Fatalf("Failure - expected an electronic only branch but got %+v", b)
+	}
+	if b, _ := d.Lookup("083-004"); !b.Closed {
+		t.Fatalf("Failure - expected a closed branch but got %+v", b)
+	}
+	if _, err := Open("testdata/missing.csv"); err == nil {
+		t.Fatal("Expected an error but got", err)
+	}
+
+	// The published directory has no header row
+	d, err = Open("testdata/headerless.csv")
+	if err != nil {
+		t.Fatal("error opening directory", err)
+	}
+	if d.Len() != 4 {
+		t.Fatal("Expected 4 branches but got", d.Len())
+	}
+	b, _ = d.Lookup("814-282")
+	if b.Mnemonic != "CUA" || b.State != "QLD" || b.Postcode != "4000" || b.Closed || b.Flags.String() != "E" {
+		t.Fatalf("Failure - expected every column but got %+v", b)
+	}
+	if b, ok := d.Lookup("704-235"); !ok || b.Closed {
+		t.Fatalf("Failure - expected an open branch but got %+v", b)
+	}
+	if b, _ := d.Lookup("814-283"); !b.Closed {
+		t.Fatalf("Failure - expected a closed branch but got %+v", b)
+	}
+	if _, err = Parse(strings.NewReader("012-003,ANZ\n012003,ANZ\n")); !errors.Is(err, ErrBadBSB) {
+		t.Fatal("Expected an error but got", err)
+	}
+}
@@ -0,0 +1,265 @@
+// Package camt exports TXN statements as ISO 20022 bank to customer
+// statements, camt.053.001.02.
+package camt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/17twenty/txn"
+	"github.com/shopspring/decimal"
+)
+
+// Namespace of the camt.053.001.02 documents written
+const Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+const (
+	dateFormat     = "2006-01-02"
+	dateTimeFormat = "2006-01-02T15:04:05"
+	clearingSystem = "AUBSB" // Australian BSB clearing system identifier
+	maxText        = 140     // longest unstructured remittance information
+)
+
+// Encoder writes camt.053 documents to an output stream
+type Encoder struct {
+	// Currency of every statement, AUD if empty
+	Currency string
+	w        io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+type document struct {
+	XMLName xml.Name      `xml:"Document"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Stmts   bkToCstmrStmt `xml:"BkToCstmrStmt"`
+}
+
+type bkToCstmrStmt struct {
+	GrpHdr grpHdr `xml:"GrpHdr"`
+	Stmt   []stmt `xml:"Stmt"`
+}
+
+type grpHdr struct {
+	MsgId    string `xml:"MsgId"`
+	CreDtTm  string `xml:"CreDtTm"`
+	AddtlInf string `xml:"AddtlInf,omitempty"`
+}
+
+type stmt struct {
+	Id           string     `xml:"Id"`
+	ElctrncSeqNb string     `xml:"ElctrncSeqNb,omitempty"`
+	CreDtTm      string     `xml:"CreDtTm"`
+	Acct         acct       `xml:"Acct"`
+	Bal          []bal      `xml:"Bal"`
+	TxsSummry    *txsSummry `xml:"TxsSummry"`
+	Ntry         []ntry     `xml:"Ntry"`
+}
+
+type acct struct {
+	Id   string `xml:"Id>Othr>Id"`
+	Ccy  string `xml:"Ccy,omitempty"`
+	Nm   string `xml:"Nm,omitempty"`
+	Svcr *svcr  `xml:"Svcr"`
+}
+
+type svcr struct {
+	ClrSysId string `xml:"FinInstnId>ClrSysMmbId>ClrSysId>Cd"`
+	MmbId    string `xml:"FinInstnId>ClrSysMmbId>MmbId"`
+}
+
+type amount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type bal struct {
+	Tp        string `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       amount `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	Dt        string `xml:"Dt>Dt"`
+}
+
+type txsSummry struct {
+	TtlCdtNtries sum `xml:"TtlCdtNtries"`
+	TtlDbtNtries sum `xml:"TtlDbtNtries"`
+}
+
+type sum struct {
+	NbOfNtries string `xml:"NbOfNtries"`
+	Sum        string `xml:"Sum"`
+}
+
+type ntry struct {
+	NtryRef     string  `xml:"NtryRef,omitempty"`
+	Amt         amount  `xml:"Amt"`
+	CdtDbtInd   string  `xml:"CdtDbtInd"`
+	Sts         string  `xml:"Sts"`
+	BookgDt     string  `xml:"BookgDt>Dt"`
+	ValDt       string  `xml:"ValDt>Dt"`
+	AcctSvcrRef string  `xml:"AcctSvcrRef,omitempty"`
+	BkTxCd      bkTxCd  `xml:"BkTxCd"`
+	NtryDtls    *txDtls `xml:"NtryDtls>TxDtls"`
+}
+
+type bkTxCd struct {
+	Cd   string `xml:"Prtry>Cd"`
+	Issr string `xml:"Prtry>Issr,omitempty"`
+}
+
+type txDtls struct {
+	Refs   *refs  `xml:"Refs"`
+	RmtInf string `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+type refs struct {
+	ChqNb string `xml:"ChqNb,omitempty"`
+	Prtry *prtry `xml:"Prtry"`
+}
+
+type prtry struct {
+	Tp  string `xml:"Tp"`
+	Ref string `xml:"Ref"`
+}
+
+// Encode writes f as a single camt.053 document holding an account
+// statement per Batch. The closing balance is the BatchHeader Amount and
+// the opening balance is the closing balance less the net of the
+// BatchTrailer. Each Record becomes a booked entry.
+func (e *Encoder) Encode(f *txn.File) error {
+	created := f.FileHeader.FileCreated
+	doc := document{
+		Xmlns: Namespace,
+		Stmts: bkToCstmrStmt{
+			GrpHdr: grpHdr{
+				MsgId:    "TXN" + f.FileHeader.CustomerNumber + created.Format("20060102"),
+				CreDtTm:  created.Format(dateTimeFormat),
+				AddtlInf: strings.TrimSpace(f.FileHeader.Description),
+			},
+		},
+	}
+	for i := range f.Batches {
+		doc.Stmts.Stmt = append(doc.Stmts.Stmt, e.statement(i, &f.Batches[i], created))
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "%s%s\n", xml.Header, out)
+	return err
+}
+
+func (e *Encoder) statement(i int, b *txn.Batch, created time.Time) stmt {
+	ccy := e.Currency
+	if ccy == "" {
+		ccy = "AUD"
+	}
+	h, t := &b.BatchHeader, &b.BatchTrailer
+	bsb := strings.ReplaceAll(h.BSBNumber, "-", "")
+
+	opening, closing := b.Balances()
+	start := h.TransactionDate
+	for _, r := range b.Records {
+		if r.TransactionDate.Before(start) {
+			start = r.TransactionDate
+		}
+	}
+
+	s := stmt{
+		Id:      fmt.Sprintf("%s%s-%d", bsb, h.AccountNumber, i+1),
+		CreDtTm: created.Format(dateTimeFormat),
+		Acct: acct{
+			Id:   bsb + h.AccountNumber,
+			Ccy:  ccy,
+			Nm:   h.AccountName,
+			Svcr: &svcr{ClrSysId: clearingSystem, MmbId: bsb},
+		},
+		Bal: []bal{
+			balance("OPBD", opening, start, ccy),
+			balance("CLBD", closing, h.TransactionDate, ccy),
+		},
+		TxsSummry: &txsSummry{
+			TtlCdtNtries: sum{strconv.Itoa(t.TotalCreditTransactions), t.TotalCreditAmount.StringFixedBank(2)},
+			TtlDbtNtries: sum{strconv.Itoa(t.TotalDebitTransactions), t.TotalDebitAmount.StringFixedBank(2)},
+		},
+	}
+	if t.ReferenceNumber != 0 {
+		s.ElctrncSeqNb = strconv.Itoa(t.ReferenceNumber)
+	}
+	for k := range b.Records {
+		s.Ntry = append(s.Ntry, entry(k, &b.Records[k], ccy))
+	}
+	return s
+}
+
+func balance(code string, amt decimal.Decimal, date time.Time, ccy string) bal {
+	return bal{
+		Tp:        code,
+		Amt:       amount{ccy, amt.Abs().StringFixedBank(2)},
+		CdtDbtInd: indicator(amt.Sign() >= 0),
+		Dt:        date.Format(dateFormat),
+	}
+}
+
+// entry converts a Record to a booked Ntry, the TXN transaction code being
+// the proprietary bank transaction code. A blank code, which Cd doesn't
+// allow, is written as the default for the direction of the entry.
+func entry(k int, r *txn.Record, ccy string) ntry {
+	code := strings.TrimSpace(r.TransactionCode)
+	if code == "" && r.Indicator == txn.Credit {
+		code = txn.DefaultTransactionCode(txn.Credit)
+	} else if code == "" {
+		code = txn.DefaultTransactionCode(txn.Debit)
+	}
+	n := ntry{
+		NtryRef:   strconv.Itoa(k + 1),
+		Amt:       amount{ccy, r.Amount.StringFixedBank(2)},
+		CdtDbtInd: indicator(r.Indicator == txn.Credit),
+		Sts:       "BOOK",
+		BookgDt:   r.TransactionDate.Format(dateFormat),
+		ValDt:     r.TransactionDate.Format(dateFormat),
+		BkTxCd:    bkTxCd{Cd: code, Issr: "TXN"},
+	}
+	if r.ReferenceNumber != 0 {
+		n.AcctSvcrRef = strconv.Itoa(r.ReferenceNumber)
+	}
+
+	d := &txDtls{RmtInf: text(r.Description)}
+	if c := strings.TrimSpace(r.ChequeNumber); c != "" {
+		d.Refs = &refs{ChqNb: c}
+	}
+	if ref := strings.TrimSpace(r.SecondaryReferenceNumber); ref != "" {
+		if d.Refs == nil {
+			d.Refs = &refs{}
+		}
+		d.Refs.Prtry = &prtry{Tp: "SecondaryReferenceNumber", Ref: ref}
+	}
+	if d.Refs != nil || d.RmtInf != "" {
+		n.NtryDtls = d
+	}
+	return n
+}
+
+func indicator(credit bool) string {
+	if credit {
+		return "CRDT"
+	}
+	return "DBIT"
+}
+
+// text collapses the padding of a fixed width description
+func text(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxText {
+		s = s[:maxText]
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package camt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/17twenty/txn"
+	"github.com/17twenty/txn/internal/sample"
+)
+
+func TestEncode(t *testing.T) {
+	file := sample.Read(t)
+	file.Batches[0].Records[1].TransactionCode = ""
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(file); err != nil {
+		t.Fatal("error encoding", err)
+	}
+
+	var doc document
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("error parsing output", err)
+	}
+	if doc.XMLName.Space != Namespace || len(doc.Stmts.Stmt) != 1 {
+		t.Fatalf("Failure - expected 1 camt.053 statement but got %+v", doc)
+	}
+	s := doc.Stmts.Stmt[0]
+	if s.Acct.Id != "182222117867898" || s.Acct.Svcr.MmbId != "182222" || s.ElctrncSeqNb != "1" {
+		t.Fatalf("Failure - unexpected statement %+v", s)
+	}
+	// Closing 426.32 CR after a net debit of 1211.18
+	if s.Bal[0] != (bal{"OPBD", amount{"AUD", "1637.50"}, "CRDT", "2012-07-02"}) {
+		t.Fatalf("Failure - unexpected opening balance %+v", s.Bal[0])
+	}
+	if s.Bal[1] != (bal{"CLBD", amount{"AUD", "426.32"}, "CRDT", "2012-07-31"}) {
+		t.Fatalf("Failure - unexpected closing balance %+v", s.Bal[1])
+	}
+	if len(s.Ntry) != 10 {
+		t.Fatal("Expected 10 entries but got", len(s.Ntry))
+	}
+	n := s.Ntry[0]
+	if n.Amt.Value != "2721.78" || n.CdtDbtInd != "DBIT" || n.BkTxCd.Cd != "13" || n.AcctSvcrRef != "245397" || n.NtryDtls.RmtInf != "DDR GL481 Tower Australia" {
+		t.Fatalf("Failure - unexpected entry %+v", n)
+	}
+	if s.Ntry[1].CdtDbtInd != "CRDT" || s.Ntry[1].BkTxCd.Cd != "50" || s.TxsSummry.TtlDbtNtries != (sum{"5", "11860.14"}) {
+		t.Fatalf("Failure - unexpected entry %+v", s.Ntry[1])
+	}
+}
+
+// TestSchema validates the output against the published camt.053.001.02
+// schema, as downloaded from the ISO 20022 message catalogue into testdata,
+// using xmllint.
+func TestSchema(t *testing.T) {
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not installed")
+	}
+	schema := "testdata/camt.053.001.02.xsd"
+	if _, err := os.Stat(schema); err != nil {
+		t.Skip("published schema not in testdata, download camt.053.001.02.xsd from iso20022.org")
+	}
+	// A blank transaction code still gives a valid Cd
+	file := sample.Read(t)
+	file.Batches[0].Records[1].TransactionCode = ""
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(file); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	doc := filepath.Join(t.TempDir(), "camt.053.xml")
+	if err := os.WriteFile(doc, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(xmllint, "--noout", "--schema", schema, doc).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failure - output doesn't validate: %v\n%s", err, out)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	original := sample.Read(t)
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(original); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	file, report, err := NewDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal("error decoding", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Fatal("Expected nothing to be lost but got", report.Findings)
+	}
+	if len(file.Batches) != 1 || len(file.Batches[0].Records) != 10 {
+		t.Fatalf("Failure - expected 1 batch of 10 records but got %+v", file.Batches)
+	}
+	b, want := file.Batches[0], original.Batches[0]
+	h := b.BatchHeader
+	if h.BSBNumber != "182-222" || h.AccountNumber != "117867898" || h.AccountName != want.BatchHeader.AccountName ||
+		!h.Amount.Equal(want.BatchHeader.Amount) || h.Indicator != txn.Credit {
+		t.Fatalf("Failure - unexpected batch header %+v", h)
+	}
+	r := b.Records[0]
+	if !r.Amount.Equal(want.Records[0].Amount) || r.Indicator != txn.Debit || r.TransactionCode != "13" ||
+		r.ReferenceNumber != 245397 || r.Description != "DDR GL481 Tower Australia" || r.BSBNumber != "182-222" {
+		t.Fatalf("Failure - unexpected record %+v", r)
+	}
+	if bt := b.BatchTrailer; !bt.Amount.Equal(want.BatchTrailer.Amount) || bt.Indicator != txn.Debit || bt.TotalDebitTransactions != 5 {
+		t.Fatalf("Failure - unexpected batch trailer %+v", bt)
+	}
+}
+
+func TestDecodeReport(t *testing.T) {
+	doc := `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"><BkToCstmrStmt>
+<GrpHdr><MsgId>1</MsgId><CreDtTm>2012-08-01T09:00:00</CreDtTm></GrpHdr>
+<Stmt><Id>1</Id><Acct><Id><IBAN>GB29NWBK60161331926819</IBAN></Id></Acct>
+<Bal><Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp><Amt Ccy="AUD">10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Dt><Dt>2012-07-31</Dt></Dt></Bal>
+<Ntry><Amt Ccy="USD">10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>PDNG</Cd></Sts><BookgDt><DtTm>2012-07-31T10:00:00</DtTm></BookgDt>
+<BkTxCd><Domn><Cd>PMNT</Cd></Domn></BkTxCd>
+<NtryDtls><TxDtls><RmtInf><Ustrd>A description far too long for the forty characters of TXN</Ustrd></RmtInf></TxDtls></NtryDtls></Ntry>
+</Stmt></BkToCstmrStmt></Document>`
+	file, report, err := NewDecoder(strings.NewReader(doc)).Decode()
+	if err != nil {
+		t.Fatal("error decoding", err)
+	}
+	r := file.Batches[0].Records[0]
+	if r.TransactionCode != "50" || r.Description != "A description far too long for the forty" {
+		t.Fatalf("Failure - unexpected record %+v", r)
+	}
+	var lost, cut int
+	for _, f := range report.Warnings() {
+		switch {
+		case errors.Is(f.Err, txn.ErrNotRepresentable):
+			lost++
+		case errors.Is(f.Err, txn.ErrTruncated):
+			cut++
+		}
+	}
+	// The IBAN, currency, status and bank transaction code
+	if lost != 4 || cut != 1 || len(report.Errors()) != 0 {
+		t.Fatal("Expected 4 lost values and 1 truncated but got", report.Findings)
+	}
+}
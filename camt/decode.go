@@ -0,0 +1,315 @@
+package camt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/17twenty/txn"
+	"github.com/17twenty/txn/internal/importer"
+	"github.com/shopspring/decimal"
+)
+
+var digitsRegEx = regexp.MustCompile(`^\d+$`)
+
+// Decoder reads camt.053 documents from an input stream. Any version of
+// the message is accepted as only elements common to them all are read.
+type Decoder struct {
+	// Currency expected of every amount, AUD if empty. Others are reported
+	// as TXN amounts have no currency.
+	Currency string
+	r        io.Reader
+}
+
+// NewDecoder returns a new Decoder that reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+type inDocument struct {
+	CreDtTm string   `xml:"BkToCstmrStmt>GrpHdr>CreDtTm"`
+	Stmts   []inStmt `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type inStmt struct {
+	ElctrncSeqNb string `xml:"ElctrncSeqNb"`
+	Acct         struct {
+		IBAN  string `xml:"Id>IBAN"`
+		Othr  string `xml:"Id>Othr>Id"`
+		Nm    string `xml:"Nm"`
+		MmbId string `xml:"Svcr>FinInstnId>ClrSysMmbId>MmbId"`
+	} `xml:"Acct"`
+	Bal  []inBal  `xml:"Bal"`
+	Ntry []inNtry `xml:"Ntry"`
+}
+
+type inBal struct {
+	Cd        string `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       amount `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	Dt        inDate `xml:"Dt"`
+}
+
+// inDate is a DateAndDateTimeChoice
+type inDate struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+// inStatus is the entry status, a code in early versions and a choice
+// holding the code in later ones
+type inStatus struct {
+	Text string `xml:",chardata"`
+	Cd   string `xml:"Cd"`
+}
+
+type inNtry struct {
+	Amt          amount     `xml:"Amt"`
+	CdtDbtInd    string     `xml:"CdtDbtInd"`
+	RvslInd      bool       `xml:"RvslInd"`
+	Sts          inStatus   `xml:"Sts"`
+	BookgDt      inDate     `xml:"BookgDt"`
+	ValDt        inDate     `xml:"ValDt"`
+	AcctSvcrRef  string     `xml:"AcctSvcrRef"`
+	Prtry        string     `xml:"BkTxCd>Prtry>Cd"`
+	Domn         string     `xml:"BkTxCd>Domn>Cd"`
+	TxDtls       []inTxDtls `xml:"NtryDtls>TxDtls"`
+	AddtlNtryInf string     `xml:"AddtlNtryInf"`
+}
+
+type inTxDtls struct {
+	ChqNb      string   `xml:"Refs>ChqNb"`
+	EndToEndId string   `xml:"Refs>EndToEndId"`
+	Prtry      []prtry  `xml:"Refs>Prtry"`
+	Ustrd      []string `xml:"RmtInf>Ustrd"`
+	AddtlTxInf string   `xml:"AddtlTxInf"`
+}
+
+// Decode reads a camt.053 document into a File holding a Batch per
+// statement, the inverse of Encoder.Encode. Anything the TXN fields can't
+// hold is reported as a warning in the ValidationReport.
+func (d *Decoder) Decode() (*txn.File, txn.ValidationReport, error) {
+	var doc inDocument
+	if err := xml.NewDecoder(d.r).Decode(&doc); err != nil {
+		return nil, txn.ValidationReport{}, err
+	}
+
+	s := statement{currency: d.Currency}
+	if s.currency == "" {
+		s.currency = "AUD"
+	}
+	f := &s.File
+	f.FileHeader.Description = "ACCOUNT TRANSACTIONS"
+	if t, err := parseDate(doc.CreDtTm); err == nil {
+		f.FileHeader.FileCreated, f.FileHeader.ProcessingDate = t, t
+	}
+	for i := range doc.Stmts {
+		if err := s.decode(i, &doc.Stmts[i]); err != nil {
+			return nil, s.Report, err
+		}
+	}
+	f.SetTrailers()
+	return f, s.Report, nil
+}
+
+// statement accumulates the File and report while decoding
+type statement struct {
+	importer.Import
+	currency string
+}
+
+// at is the position of an element, reported by its path
+func at(batch, record int, element string) txn.Finding {
+	return txn.Finding{Batch: batch, Record: record, Field: element}
+}
+
+func (s *statement) decode(i int, st *inStmt) error {
+	var b txn.Batch
+	h := &b.BatchHeader
+	s.account(i, st, h)
+	if n, err := strconv.Atoi(st.ElctrncSeqNb); err == nil {
+		b.BatchTrailer.ReferenceNumber = n
+	}
+
+	var opening, closing *inBal
+	for k := range st.Bal {
+		switch bal := &st.Bal[k]; bal.Cd {
+		case "OPBD", "PRCD":
+			opening = bal
+		case "CLBD":
+			closing = bal
+		default:
+			s.Warn(at(i, -1, "Stmt/Bal"), fmt.Errorf("%w: %s balance", txn.ErrNotRepresentable, bal.Cd))
+		}
+	}
+	if closing != nil {
+		amount, err := s.amount(i, -1, "Stmt/Bal/Amt", closing.Amt, closing.CdtDbtInd)
+		if err != nil {
+			return err
+		}
+		h.Amount, h.Indicator = amount.Abs(), txn.Credit
+		if amount.Sign() < 0 {
+			h.Indicator = txn.Debit
+		}
+		if h.TransactionDate, err = closing.Dt.time(); err != nil {
+			return err
+		}
+		b.BatchTrailer.TransactionDate = h.TransactionDate
+	}
+	b.BatchTrailer.BatchType = txn.BatchTXN
+
+	for k := range st.Ntry {
+		r, err := s.entry(i, k, &st.Ntry[k])
+		if err != nil {
+			return err
+		}
+		r.BSBNumber, r.AccountNumber, r.AccountName = h.BSBNumber, h.AccountNumber, h.AccountName
+		b.Records = append(b.Records, r)
+	}
+
+	if opening != nil && closing != nil {
+		open, err := s.amount(i, -1, "Stmt/Bal/Amt", opening.Amt, opening.CdtDbtInd)
+		if err != nil {
+			return err
+		}
+		s.Reconcile(at(i, -1, "Stmt/Bal"), &b, open)
+	}
+	s.File.Batches = append(s.File.Batches, b)
+	return nil
+}
+
+// account fills in the BSB and account number from the account
+// identification, the BSB being the clearing system member id if given
+func (s *statement) account(i int, st *inStmt, h *txn.BatchHeader) {
+	h.AccountName = s.Fit(at(i, -1, "Stmt/Acct/Nm"), txn.BatchHeaderLayout(), "AccountName", st.Acct.Nm)
+
+	id := st.Acct.Othr
+	bsb := strings.ReplaceAll(st.Acct.MmbId, "-", "")
+	if bsb == "" && len(id) > 6 {
+		bsb = id[:6]
+	}
+	account := strings.TrimPrefix(id, bsb)
+	if st.Acct.IBAN != "" || len(bsb) != 6 || !digitsRegEx.MatchString(bsb) ||
+		len(account) == 0 || len(account) > 9 || !digitsRegEx.MatchString(account) {
+		id += st.Acct.IBAN
+		s.Warn(at(i, -1, "Stmt/Acct/Id"), fmt.Errorf("%w: account %q isn't a BSB and account number", txn.ErrNotRepresentable, id))
+		if h.AccountName == "" {
+			h.AccountName, _ = importer.Truncate(txn.BatchHeaderLayout(), "AccountName", id)
+		}
+		return
+	}
+	h.BSBNumber, h.AccountNumber = bsb[:3]+"-"+bsb[3:], account
+}
+
+// entry converts an Ntry to a Record. A proprietary bank transaction code
+// is the TransactionCode when Import.TransactionCode keeps it. A numeric
+// AcctSvcrRef becomes the ReferenceNumber and the first transaction details
+// give the remaining references and the description.
+func (s *statement) entry(i, k int, n *inNtry) (txn.Record, error) {
+	amount, err := s.amount(i, k, "Ntry/Amt", n.Amt, n.CdtDbtInd)
+	if err != nil {
+		return txn.Record{}, err
+	}
+	r := txn.Record{Amount: amount.Abs(), Indicator: txn.Credit}
+	if amount.Sign() < 0 {
+		r.Indicator = txn.Debit
+	}
+	date := n.BookgDt
+	if date.Dt == "" && date.DtTm == "" {
+		date = n.ValDt
+	}
+	if r.TransactionDate, err = date.time(); err != nil {
+		return txn.Record{}, err
+	}
+
+	if sts := strings.TrimSpace(n.Sts.Text + n.Sts.Cd); sts != "BOOK" {
+		s.Warn(at(i, k, "Ntry/Sts"), fmt.Errorf("%w: entry status %s", txn.ErrNotRepresentable, sts))
+	}
+	if n.RvslInd {
+		s.Warn(at(i, k, "Ntry/RvslInd"), fmt.Errorf("%w: reversal", txn.ErrNotRepresentable))
+	}
+
+	code := n.Prtry
+	if code == "" {
+		code = n.Domn
+	}
+	r.TransactionCode = s.TransactionCode(at(i, k, "Ntry/BkTxCd"), code, r.Indicator)
+
+	if ref := strings.TrimSpace(n.AcctSvcrRef); digitsRegEx.MatchString(ref) && len(ref) <= 10 {
+		r.ReferenceNumber, _ = strconv.Atoi(ref)
+	} else if ref != "" {
+		r.SecondaryReferenceNumber = s.Fit(at(i, k, "Ntry/AcctSvcrRef"), txn.RecordLayout(), "SecondaryReferenceNumber", ref)
+	}
+
+	desc := n.AddtlNtryInf
+	if len(n.TxDtls) > 0 {
+		tx := &n.TxDtls[0]
+		if len(n.TxDtls) > 1 {
+			s.Warn(at(i, k, "Ntry/NtryDtls/TxDtls"), fmt.Errorf("%w: %d transactions in one entry, only the first is kept", txn.ErrNotRepresentable, len(n.TxDtls)))
+		}
+		if tx.ChqNb != "" {
+			r.ChequeNumber = s.Fit(at(i, k, "Ntry/NtryDtls/TxDtls/Refs/ChqNb"), txn.RecordLayout(), "ChequeNumber", tx.ChqNb)
+		}
+		refs := tx.Prtry
+		if e := strings.TrimSpace(tx.EndToEndId); e != "" && e != "NOTPROVIDED" {
+			refs = append(refs, prtry{Tp: "EndToEndId", Ref: e})
+		}
+		for _, p := range refs {
+			if r.SecondaryReferenceNumber == "" {
+				r.SecondaryReferenceNumber = s.Fit(at(i, k, "Ntry/NtryDtls/TxDtls/Refs"), txn.RecordLayout(), "SecondaryReferenceNumber", p.Ref)
+				continue
+			}
+			s.Warn(at(i, k, "Ntry/NtryDtls/TxDtls/Refs"), fmt.Errorf("%w: %s %q", txn.ErrNotRepresentable, p.Tp, p.Ref))
+		}
+
+		switch {
+		case len(tx.Ustrd) > 0:
+			if desc != "" {
+				s.Warn(at(i, k, "Ntry/AddtlNtryInf"), fmt.Errorf("%w: %q", txn.ErrNotRepresentable, desc))
+			}
+			desc = strings.Join(tx.Ustrd, " ")
+		case tx.AddtlTxInf != "" && desc == "":
+			desc = tx.AddtlTxInf
+		case tx.AddtlTxInf != "":
+			s.Warn(at(i, k, "Ntry/NtryDtls/TxDtls/AddtlTxInf"), fmt.Errorf("%w: %q", txn.ErrNotRepresentable, tx.AddtlTxInf))
+		}
+	}
+	r.Description = s.Fit(at(i, k, "Ntry/NtryDtls/TxDtls/RmtInf"), txn.RecordLayout(), "Description", strings.Join(strings.Fields(desc), " "))
+	return r, nil
+}
+
+// amount decodes a signed amount, warning when it's in another currency
+func (s *statement) amount(i, k int, element string, a amount, cdtDbtInd string) (decimal.Decimal, error) {
+	v, err := decimal.NewFromString(strings.TrimSpace(a.Value))
+	if err != nil {
+		return v, fmt.Errorf("camt: %s: %w", element, err)
+	}
+	if a.Ccy != s.currency {
+		s.Warn(at(i, k, element), fmt.Errorf("%w: currency %s", txn.ErrNotRepresentable, a.Ccy))
+	}
+	if cdtDbtInd == "DBIT" {
+		v = v.Neg()
+	}
+	return v, nil
+}
+
+// time returns the date of a DateAndDateTimeChoice
+func (d inDate) time() (time.Time, error) {
+	if d.Dt != "" {
+		return time.Parse(dateFormat, strings.TrimSpace(d.Dt))
+	}
+	return parseDate(d.DtTm)
+}
+
+// parseDate reads the date of an ISODateTime, ignoring the time
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > len(dateFormat) {
+		s = s[:len(dateFormat)]
+	}
+	return time.Parse(dateFormat, s)
+}
@@ -0,0 +1,26 @@
+package txn
+
+import "time"
+
+// Clock supplies the current time to a Writer
+type Clock func() time.Time
+
+// FixedClock returns a Clock that always reports t, making the output of
+// a Writer reproducible
+func FixedClock(t time.Time) Clock {
+	return func() time.Time {
+		return t
+	}
+}
+
+// now returns the time from the Writer's Clock in its Location
+func (w *Writer) now() time.Time {
+	now := time.Now
+	if w.Clock != nil {
+		now = w.Clock
+	}
+	if w.Location != nil {
+		return now().In(w.Location)
+	}
+	return now()
+}
@@ -0,0 +1,68 @@
+package txn
+
+import "sync"
+
+// TransactionCode describes a code a Record may carry
+type TransactionCode struct {
+	Code        string
+	Description string
+	// Indicator is the direction the code posts in, Debit or Credit, or
+	// empty if it may be used for either
+	Indicator Indicator
+}
+
+var (
+	codesMu          sync.RWMutex
+	transactionCodes = map[string]TransactionCode{}
+)
+
+func init() {
+	for _, tc := range []TransactionCode{
+		{"13", "Externally initiated debit", Debit},
+		{"50", "Externally initiated credit", Credit},
+		{"51", "Australian Government security interest", Credit},
+		{"52", "Family allowance", Credit},
+		{"53", "Pay", Credit},
+		{"54", "Pension", Credit},
+		{"55", "Allotment", Credit},
+		{"56", "Dividend", Credit},
+		{"57", "Debenture or note interest", Credit},
+	} {
+		RegisterTransactionCode(tc)
+	}
+}
+
+// RegisterTransactionCode adds a bank specific code to the registry or
+// replaces the description and direction of an existing one
+func RegisterTransactionCode(tc TransactionCode) {
+	codesMu.Lock()
+	defer codesMu.Unlock()
+	transactionCodes[tc.Code] = tc
+}
+
+// LookupTransactionCode returns the registered details of code
+func LookupTransactionCode(code string) (TransactionCode, bool) {
+	codesMu.RLock()
+	defer codesMu.RUnlock()
+	tc, ok := transactionCodes[code]
+	return tc, ok
+}
+
+// DefaultTransactionCode returns the code a record with indicator carries
+// when none is given, 13 for a debit and 50 for a credit
+func DefaultTransactionCode(indicator Indicator) string {
+	switch indicator {
+	case Debit:
+		return "13"
+	case Credit:
+		return "50"
+	}
+	return ""
+}
+
+// matchesIndicator reports whether code may be used with indicator,
+// unregistered codes may be used with either
+func matchesIndicator(code string, indicator Indicator) bool {
+	tc, ok := LookupTransactionCode(code)
+	return !ok || tc.Indicator == "" || tc.Indicator == indicator
+}
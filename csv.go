@@ -0,0 +1,280 @@
+package txn
+
+import (
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrMissingColumn = errors.New("txn: CSV header is missing a column")
+	ErrUnknownColumn = errors.New("txn: CSV column maps to no Record field")
+)
+
+// CSVColumn maps a CSV column to a Record field such as Amount. The batch
+// a record belongs to is given by the BatchBSBNumber, BatchAccountNumber
+// and BatchAccountName columns.
+type CSVColumn struct {
+	Field  string
+	Header string
+}
+
+// DefaultCSVColumns are used when a CSV has no Columns
+var DefaultCSVColumns = []CSVColumn{
+	{"BatchBSBNumber", "batch_bsb"},
+	{"BatchAccountNumber", "batch_account_number"},
+	{"BatchAccountName", "batch_account_name"},
+	{"BSBNumber", "bsb"},
+	{"AccountNumber", "account_number"},
+	{"AccountName", "account_name"},
+	{"TransactionDate", "transaction_date"},
+	{"Amount", "amount"},
+	{"Indicator", "indicator"},
+	{"TransactionCode", "transaction_code"},
+	{"Description", "description"},
+	{"ReferenceNumber", "reference_number"},
+	{"SecondaryReferenceNumber", "secondary_reference_number"},
+	{"ChequeNumber", "cheque_number"},
+}
+
+// batchColumns are the BatchHeader fields a record's batch is keyed by
+var batchColumns = []string{"BatchBSBNumber", "BatchAccountNumber", "BatchAccountName"}
+
+// CSV converts Records, grouped in Batches, to and from CSV with a header
+// row, e.g. for payment runs prepared in a spreadsheet
+type CSV struct {
+	// Columns in the order they are written, DefaultCSVColumns if nil.
+	// Reading requires every column in the header, in any order, and
+	// ignores any others.
+	Columns []CSVColumn
+	// DateFormat is the time layout of dates, 2006-01-02 if empty
+	DateFormat string
+	// SignedAmounts writes debits as negative amounts and reads the
+	// Indicator from the sign, so no Indicator column is needed
+	SignedAmounts bool
+	// Comma is the field delimiter, ',' if zero
+	Comma rune
+}
+
+func (c *CSV) columns() []CSVColumn {
+	if c.Columns == nil {
+		return DefaultCSVColumns
+	}
+	return c.Columns
+}
+
+func (c *CSV) dateFormat() string {
+	if c.DateFormat == "" {
+		return "2006-01-02"
+	}
+	return c.DateFormat
+}
+
+// field returns the Record field of a column, false for a batch column
+func (c *CSV) field(col CSVColumn) (Field, bool, error) {
+	for _, name := range batchColumns {
+		if col.Field == name {
+			return Field{}, false, nil
+		}
+	}
+	f, ok := recordLayout.Field(col.Field)
+	if !ok {
+		return f, false, fmt.Errorf("%w: %s", ErrUnknownColumn, col.Field)
+	}
+	return f, true, nil
+}
+
+// Decode reads the records of in into batches, ready for Writer.Batch.
+// Consecutive rows for the same batch account share a Batch. Every row
+// that fails to convert or validate is reported as a *ParseError with the
+// CSV line number, the first data row being line 2, alongside the batches
+// of the rows that were fine.
+func (c *CSV) Decode(in io.Reader) ([]Batch, error) {
+	cr := csv.NewReader(in)
+	if c.Comma != 0 {
+		cr.Comma = c.Comma
+	}
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, h := range header {
+		index[strings.TrimSpace(h)] = i
+	}
+	cols := c.columns()
+	for _, col := range cols {
+		if _, ok := index[col.Header]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, col.Header)
+		}
+		if _, _, err := c.field(col); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		batches []Batch
+		errs    ParseErrors
+	)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return batches, err
+		}
+		line, _ := cr.FieldPos(0)
+		raw := strings.Join(row, string(cr.Comma))
+
+		var (
+			r    Record
+			h    BatchHeader
+			rerr ParseErrors
+		)
+		rv := reflect.ValueOf(&r).Elem()
+		for _, col := range cols {
+			s := strings.TrimSpace(row[index[col.Header]])
+			f, isRecord, _ := c.field(col)
+			if !isRecord {
+				reflect.ValueOf(&h).Elem().FieldByName(strings.TrimPrefix(col.Field, "Batch")).SetString(s)
+				continue
+			}
+			if err := c.decodeField(f, s, rv.FieldByName(f.Name)); err != nil {
+				err = fmt.Errorf("%w: column %s: %w", ErrBadRecord, col.Header, err)
+				rerr = append(rerr, &ParseError{Line: line, RecordType: "Record", Field: "Record." + f.Name, Raw: raw, Err: err})
+			}
+		}
+		if c.SignedAmounts && r.Amount.Sign() < 0 {
+			r.Amount, r.Indicator = r.Amount.Neg(), Debit
+		} else if c.SignedAmounts {
+			r.Indicator = Credit
+		}
+		for _, fe := range r.invalidFields(new(Dialect)) {
+			if rerr.field(fe.Field) == nil {
+				rerr = append(rerr, &ParseError{Line: line, RecordType: "Record", Field: fe.Field, Raw: raw, Err: fe.Err})
+			}
+		}
+		if len(rerr) > 0 {
+			errs = append(errs, rerr...)
+			continue
+		}
+
+		n := len(batches) - 1
+		if n < 0 || !sameAccount(&batches[n].BatchHeader, &h) {
+			h.recordType = 1
+			batches = append(batches, Batch{BatchHeader: h})
+			n++
+		}
+		r.recordType = 2
+		batches[n].Records = append(batches[n].Records, r)
+	}
+	return batches, errs.asError()
+}
+
+func sameAccount(a, b *BatchHeader) bool {
+	return a.BSBNumber == b.BSBNumber && a.AccountNumber == b.AccountNumber && a.AccountName == b.AccountName
+}
+
+// decodeField converts the text of a column into the Record field fv
+func (c *CSV) decodeField(f Field, s string, fv reflect.Value) error {
+	switch f.Type {
+	case Numeric:
+		if s == "" {
+			return nil
+		}
+		v, err := strconv.Atoi(s)
+		fv.SetInt(int64(v))
+		return err
+	case Amount:
+		s = strings.NewReplacer("$", "", ",", "", " ", "").Replace(s)
+		if s == "" {
+			return errBlankField
+		}
+		v, err := decimal.NewFromString(s)
+		fv.Set(reflect.ValueOf(v))
+		return err
+	case Date:
+		// A blank date is the zero time, as Encode writes it
+		if s == "" {
+			return nil
+		}
+		v, err := time.Parse(c.dateFormat(), s)
+		fv.Set(reflect.ValueOf(v))
+		return err
+	}
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok && s != "" {
+		return u.UnmarshalText([]byte(s))
+	}
+	fv.SetString(s)
+	return nil
+}
+
+// Encode writes the records of batches to out as CSV, e.g. those read by
+// a Reader, starting with a header row
+func (c *CSV) Encode(out io.Writer, batches []Batch) error {
+	cw := csv.NewWriter(out)
+	if c.Comma != 0 {
+		cw.Comma = c.Comma
+	}
+	cols := c.columns()
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		if _, _, err := c.field(col); err != nil {
+			return err
+		}
+		header[i] = col.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(cols))
+	for _, b := range batches {
+		hv := reflect.ValueOf(b.BatchHeader)
+		for _, r := range b.Records {
+			rv := reflect.ValueOf(r)
+			for i, col := range cols {
+				f, isRecord, _ := c.field(col)
+				if !isRecord {
+					row[i] = hv.FieldByName(strings.TrimPrefix(col.Field, "Batch")).String()
+					continue
+				}
+				row[i] = c.encodeField(f, rv.FieldByName(f.Name), &r)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// encodeField formats the Record field fv as the text of a column
+func (c *CSV) encodeField(f Field, fv reflect.Value, r *Record) string {
+	switch f.Type {
+	case Numeric:
+		return strconv.FormatInt(fv.Int(), 10)
+	case Amount:
+		if c.SignedAmounts {
+			return r.SignedAmount().StringFixedBank(2)
+		}
+		return fv.Interface().(decimal.Decimal).StringFixedBank(2)
+	case Date:
+		t := fv.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(c.dateFormat())
+	}
+	return fv.String()
+}
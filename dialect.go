@@ -0,0 +1,77 @@
+package txn
+
+// Dialect captures the differences between the TXN files of different
+// banks. The zero value is the layout this package has always read and
+// written, which is Macquarie's. Set it on a Reader or Writer before use.
+type Dialect struct {
+	Name string
+	// LineWidth overrides the width of header and trailer lines and
+	// RecordWidth that of records. Zero keeps the width of the Layout and a
+	// width is never narrower than the fields of the line.
+	LineWidth   int
+	RecordWidth int
+	// LineEnding is written after every line, "\n" if empty. Readers accept
+	// either style regardless.
+	LineEnding string
+	// OmitBatchTotals is for banks that don't summarise the credit/debit
+	// transactions. Trailers are written with zero totals and Readers
+	// don't verify them.
+	OmitBatchTotals bool
+	// BalancingLine is for banks that require a self-balancing record, a
+	// Writer refuses to write without its Balancing set
+	BalancingLine bool
+	// TransactionCodes lists the codes a Record may carry, any if empty
+	TransactionCodes []string
+	// DateFormat is the time layout of every date field, 20060102 if empty.
+	// It must fit in 8 characters, e.g. 02012006.
+	DateFormat string
+}
+
+// Macquarie is the dialect used when none is given
+var Macquarie = Dialect{Name: "Macquarie"}
+
+// Layout returns base adjusted for the line widths and date format of d
+func (d *Dialect) Layout(base *Layout) *Layout {
+	width := d.LineWidth
+	if base.RecordType == recordLayout.RecordType {
+		width = d.RecordWidth
+	}
+	if (width == 0 || width == base.Width) && d.DateFormat == "" {
+		return base
+	}
+
+	l := *base
+	if width != 0 {
+		l.Width = width
+		for _, f := range l.Fields {
+			if f.End() > l.Width {
+				l.Width = f.End()
+			}
+		}
+	}
+	if d.DateFormat != "" {
+		l.DateFormat = d.DateFormat
+	}
+	return &l
+}
+
+// lineEnding returns the line ending written after each line
+func (d *Dialect) lineEnding() string {
+	if d.LineEnding == "" {
+		return "\n"
+	}
+	return d.LineEnding
+}
+
+// allowsCode reports whether a Record may carry the transaction code
+func (d *Dialect) allowsCode(code string) bool {
+	if len(d.TransactionCodes) == 0 {
+		return true
+	}
+	for _, c := range d.TransactionCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package txn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError describes where in a TXN file a line failed to decode.
+// Err is normally one of the ErrBad* or ErrInvalidRecord sentinels so
+// errors.Is can still be used to tell what kind of line was at fault.
+type ParseError struct {
+	Line       int    // 1-based line number, 0 when decoding a lone line
+	Offset     int64  // byte offset of the start of the line
+	RecordType string // e.g. Record or BatchHeader, empty if unknown
+	Field      string // e.g. Record.Amount, empty if the whole line is at fault
+	Start, End int    // columns of Field, matching the pos comments on each type, zero for CSV
+	Raw        string // the line as read, without the line ending
+	Err        error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	b.WriteString("parse error")
+	if e.Line > 0 {
+		fmt.Fprintf(&b, " on line %d", e.Line)
+	}
+	switch {
+	case e.Field != "" && e.End > 0:
+		fmt.Fprintf(&b, " (%s, cols %d-%d)", e.Field, e.Start, e.End)
+	case e.Field != "":
+		fmt.Fprintf(&b, " (%s)", e.Field)
+	case e.RecordType != "":
+		fmt.Fprintf(&b, " (%s)", e.RecordType)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is returned when more than one field of a line fails to decode
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// field returns the error for the named field, nil if there isn't one
+func (e ParseErrors) field(name string) *ParseError {
+	for _, pe := range e {
+		if pe.Field == name {
+			return pe
+		}
+	}
+	return nil
+}
+
+// asError returns nil, the only *ParseError or all of e as an error
+func (e ParseErrors) asError() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
+
+// FieldError describes a field that fails validation, such as before it is
+// written. Err is one of the sentinels, e.g. ErrClosedBSB, so errors.Is can
+// be used just as with a ParseError.
+type FieldError struct {
+	RecordType string // e.g. Record or BatchHeader
+	Field      string // e.g. Record.BSBNumber
+	Err        error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("validation error (%s): %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors is returned when more than one field fails validation
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// asError returns nil, the only *FieldError or all of e as an error
+func (e FieldErrors) asError() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
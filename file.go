@@ -0,0 +1,114 @@
+package txn
+
+import "github.com/shopspring/decimal"
+
+// File is a whole TXN file, as read by Reader.ReadFile
+type File struct {
+	FileHeader  FileHeader
+	Batches     []Batch
+	FileTrailer FileTrailer
+}
+
+// Summary is the number and total value of either the debits or the
+// credits of a batch or file
+type Summary struct {
+	Count  int
+	Amount decimal.Decimal
+}
+
+// SignedAmount is the amount of the record, negative for a debit
+func (r *Record) SignedAmount() decimal.Decimal {
+	return r.Amount.Mul(decimal.NewFromInt(int64(r.Indicator.Sign())))
+}
+
+// Totals summarises the debits and credits of the batch exactly as a
+// Writer totals its BatchTrailer
+func (b *Batch) Totals() (debits, credits Summary) {
+	var t tally
+	for _, r := range b.Records {
+		t.add(r)
+	}
+	return t.summaries()
+}
+
+// Net is the credits of the batch less its debits
+func (b *Batch) Net() decimal.Decimal {
+	debits, credits := b.Totals()
+	return credits.Amount.Sub(debits.Amount)
+}
+
+// Balances returns the signed opening and closing balances of the account
+// of the batch, e.g. for a statement. The closing balance is the BatchHeader
+// Amount and the opening balance is that less the net of the BatchTrailer.
+// Unlike SignedAmount a blank Indicator counts as a credit, as balances
+// usually are.
+func (b *Batch) Balances() (opening, closing decimal.Decimal) {
+	closing = signedBalance(b.BatchHeader.Amount, b.BatchHeader.Indicator)
+	return closing.Sub(signedBalance(b.BatchTrailer.Amount, b.BatchTrailer.Indicator)), closing
+}
+
+// signedBalance negates amount for a debit
+func signedBalance(amount decimal.Decimal, i Indicator) decimal.Decimal {
+	if i == Debit {
+		return amount.Neg()
+	}
+	return amount
+}
+
+// Totals summarises the debits and credits of every batch exactly as a
+// Writer totals the FileTrailer
+func (f *File) Totals() (debits, credits Summary) {
+	var t tally
+	for _, b := range f.Batches {
+		for _, r := range b.Records {
+			t.add(r)
+		}
+	}
+	return t.summaries()
+}
+
+// Net is the credits of the file less its debits
+func (f *File) Net() decimal.Decimal {
+	debits, credits := f.Totals()
+	return credits.Amount.Sub(debits.Amount)
+}
+
+// SetTrailers fills in each BatchTrailer and the FileTrailer from the
+// records, as a Writer would, e.g. for a File built from another format.
+// The date, BatchType and ReferenceNumber of each BatchTrailer are kept.
+func (f *File) SetTrailers() {
+	var file tally
+	for i := range f.Batches {
+		b := &f.Batches[i]
+		var t tally
+		for _, r := range b.Records {
+			t.add(r)
+			file.add(r)
+		}
+		bt := &b.BatchTrailer
+		bt.recordType = 7
+		bt.BSBNumber = b.BatchHeader.BSBNumber
+		bt.AccountNumber = b.BatchHeader.AccountNumber
+		bt.AccountName = b.BatchHeader.AccountName
+		bt.Amount, bt.Indicator = t.net()
+		bt.TotalDebitTransactions, bt.TotalCreditTransactions = t.debits, t.credits
+		bt.TotalDebitAmount, bt.TotalCreditAmount = t.debitAmount, t.creditAmount
+	}
+	ft := &f.FileTrailer
+	ft.recordType = 9
+	ft.TotalDebitTransactions, ft.TotalCreditTransactions = file.debits, file.credits
+	ft.TotalDebitAmount, ft.TotalCreditAmount = file.debitAmount, file.creditAmount
+}
+
+// ReadFile reads all the remaining lines from r into a File
+func (r *Reader) ReadFile() (*File, error) {
+	batches, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		FileHeader:  r.FileHeader,
+		Batches:     batches,
+		FileTrailer: r.FileTrailer,
+	}, nil
+}
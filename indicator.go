@@ -0,0 +1,96 @@
+package txn
+
+import "fmt"
+
+// Indicator is the direction of an amount, Debit or Credit
+type Indicator string
+
+// BatchType is the kind of batch summarised by a BatchTrailer
+type BatchType string
+
+// ParseIndicator returns the Indicator written as s, failing for anything
+// other than DR or CR
+func ParseIndicator(s string) (Indicator, error) {
+	switch i := Indicator(s); i {
+	case Debit, Credit:
+		return i, nil
+	}
+	return "", fmt.Errorf("%w, got %q", ErrUnknownIndicator, s)
+}
+
+func (i Indicator) String() string {
+	return string(i)
+}
+
+// Sign is -1 for a Debit, 1 for a Credit and 0 otherwise so that
+// amount.Mul(decimal.NewFromInt(int64(i.Sign()))) nets debits against credits
+func (i Indicator) Sign() int {
+	switch i {
+	case Debit:
+		return -1
+	case Credit:
+		return 1
+	}
+	return 0
+}
+
+// Opposite returns Credit for a Debit and Debit for a Credit
+func (i Indicator) Opposite() Indicator {
+	switch i {
+	case Debit:
+		return Credit
+	case Credit:
+		return Debit
+	}
+	return i
+}
+
+// MarshalText fails for anything but DR, CR and the blank zero value
+func (i Indicator) MarshalText() ([]byte, error) {
+	if _, err := ParseIndicator(string(i)); err != nil && i != "" {
+		return nil, err
+	}
+	return []byte(i), nil
+}
+
+// UnmarshalText fails for anything but DR, CR and blank
+func (i *Indicator) UnmarshalText(text []byte) error {
+	v, err := ParseIndicator(string(text))
+	if err != nil && len(text) > 0 {
+		return err
+	}
+	*i = v
+	return nil
+}
+
+// ParseBatchType returns the BatchType written as s, failing for anything
+// other than ST or SP
+func ParseBatchType(s string) (BatchType, error) {
+	switch t := BatchType(s); t {
+	case BatchTXN, BatchPAY:
+		return t, nil
+	}
+	return "", fmt.Errorf("%w, got %q", ErrUnknownBatchType, s)
+}
+
+func (t BatchType) String() string {
+	return string(t)
+}
+
+// MarshalText fails for anything but ST, SP and the blank zero value
+func (t BatchType) MarshalText() ([]byte, error) {
+	if _, err := ParseBatchType(string(t)); err != nil && t != "" {
+		return nil, err
+	}
+	return []byte(t), nil
+}
+
+// UnmarshalText fails for anything but ST, SP and blank
+func (t *BatchType) UnmarshalText(text []byte) error {
+	v, err := ParseBatchType(string(text))
+	if err != nil && len(text) > 0 {
+		return err
+	}
+	*t = v
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package importer holds what the converters of other formats into TXN,
+// such as mt940 and camt, share while building a txn.File.
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/17twenty/txn"
+	"github.com/shopspring/decimal"
+)
+
+var codeRegEx = regexp.MustCompile(`^\d{2}$`)
+
+// Import collects a File converted from another format, such as a bank
+// statement, with a report of anything its fields couldn't hold. Each
+// Finding passed in gives the position in the source, Severity and Err being
+// filled in.
+type Import struct {
+	File   txn.File
+	Report txn.ValidationReport
+}
+
+// Warn reports err at the position of at as a SeverityWarning
+func (im *Import) Warn(at txn.Finding, err error) {
+	at.Severity, at.Err = txn.SeverityWarning, err
+	im.Report.Findings = append(im.Report.Findings, at)
+}
+
+// Fail reports err at the position of at as a SeverityError
+func (im *Import) Fail(at txn.Finding, err error) {
+	at.Severity, at.Err = txn.SeverityError, err
+	im.Report.Findings = append(im.Report.Findings, at)
+}
+
+// Fit returns s trimmed to the width of the named field of l, warning with
+// ErrTruncated if anything was cut off
+func (im *Import) Fit(at txn.Finding, l *txn.Layout, name, s string) string {
+	v, cut := Truncate(l, name, s)
+	if cut {
+		im.Warn(at, fmt.Errorf("%w: %q", txn.ErrTruncated, s))
+	}
+	return v
+}
+
+// TransactionCode returns code as the TransactionCode of a record with
+// indicator if it is two digits not registered for the other direction,
+// codes TXN doesn't know of being kept. Otherwise it warns with
+// ErrNotRepresentable and returns the DefaultTransactionCode.
+func (im *Import) TransactionCode(at txn.Finding, code string, indicator txn.Indicator) string {
+	if tc, ok := txn.LookupTransactionCode(code); codeRegEx.MatchString(code) && (!ok || tc.Indicator == "" || tc.Indicator == indicator) {
+		return code
+	}
+	tc := txn.DefaultTransactionCode(indicator)
+	im.Warn(at, fmt.Errorf("%w: transaction code %q, coded as %s", txn.ErrNotRepresentable, code, tc))
+	return tc
+}
+
+// Reconcile warns with ErrTotalsMismatch unless opening plus the net of the
+// records of b is the closing balance in its BatchHeader
+func (im *Import) Reconcile(at txn.Finding, b *txn.Batch, opening decimal.Decimal) {
+	if _, closing := b.Balances(); !opening.Add(b.Net()).Equal(closing) {
+		im.Warn(at, fmt.Errorf("%w: opening balance %s and the entries don't add up to the closing balance %s", txn.ErrTotalsMismatch, opening.StringFixedBank(2), closing.StringFixedBank(2)))
+	}
+}
+
+// Truncate trims s and cuts it to the width of the named field of l,
+// reporting whether anything was cut off
+func Truncate(l *txn.Layout, name, s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	f, ok := l.Field(name)
+	if !ok || len(s) <= f.Length {
+		return s, false
+	}
+	return strings.TrimSpace(s[:f.Length]), true
+}
@@ -0,0 +1,54 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/17twenty/txn"
+	"github.com/shopspring/decimal"
+)
+
+func TestImport(t *testing.T) {
+	var im Import
+	at := txn.Finding{Line: 3, Batch: 0, Record: 1, Field: ":61:"}
+	for _, tc := range []struct {
+		code      string
+		indicator txn.Indicator
+		want      string
+	}{
+		{"39", txn.Debit, "39"}, // unregistered codes are kept
+		{"53", txn.Credit, "53"},
+		{"53", txn.Debit, "13"}, // registered for the other direction
+		{"FCHG", txn.Debit, "13"},
+		{"", txn.Credit, "50"},
+	} {
+		if got := im.TransactionCode(at, tc.code, tc.indicator); got != tc.want {
+			t.Fatal("Expected '", tc.want, "' but got", got)
+		}
+	}
+	if len(im.Report.Warnings()) != 3 || !errors.Is(im.Report.Findings[0].Err, txn.ErrNotRepresentable) || im.Report.Findings[0].Line != 3 {
+		t.Fatal("Expected 3 replaced codes but got", im.Report.Findings)
+	}
+
+	im = Import{}
+	if s := im.Fit(at, txn.RecordLayout(), "ChequeNumber", "123456789"); s != "12345678" || !errors.Is(im.Report.Findings[0].Err, txn.ErrTruncated) {
+		t.Fatal("Expected '12345678' to be truncated but got", s, im.Report.Findings)
+	}
+	if s, cut := Truncate(txn.RecordLayout(), "ChequeNumber", " 1234 "); s != "1234" || cut {
+		t.Fatal("Expected '1234' but got", s, cut)
+	}
+
+	im = Import{}
+	b := txn.Batch{
+		BatchHeader: txn.BatchHeader{Amount: decimal.RequireFromString("90"), Indicator: txn.Credit},
+		Records:     []txn.Record{{Amount: decimal.RequireFromString("10"), Indicator: txn.Debit}},
+	}
+	im.Reconcile(at, &b, decimal.RequireFromString("100"))
+	if len(im.Report.Findings) != 0 {
+		t.Fatal("Expected the batch to reconcile but got", im.Report.Findings)
+	}
+	im.Reconcile(at, &b, decimal.RequireFromString("90"))
+	if len(im.Report.Findings) != 1 || !errors.Is(im.Report.Findings[0].Err, txn.ErrTotalsMismatch) {
+		t.Fatal("Expected '", txn.ErrTotalsMismatch, "' but got", im.Report.Findings)
+	}
+}
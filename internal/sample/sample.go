@@ -0,0 +1,36 @@
+// Package sample reads the sample TXN file at the root of the module for
+// the tests of the packages converting to and from other formats.
+package sample
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/17twenty/txn"
+)
+
+// Name is the file name of the sample, one batch of 10 records
+const Name = "Test_TXN_20170123.txn"
+
+// Path returns the path of the sample wherever the test runs from
+func Path() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", Name)
+}
+
+// Read returns the sample as a File, failing t if it can't be read
+func Read(t testing.TB) *txn.File {
+	t.Helper()
+	f, err := os.Open(Path())
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	defer f.Close()
+	file, err := txn.NewReader(f).ReadFile()
+	if err != nil {
+		t.Fatal("error reading", err)
+	}
+	return file
+}
@@ -0,0 +1,288 @@
+package txn
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// The JSON form of a File is
+//
+//	{
+//	  "header": {"customer_number", "customer_name", "remitter_name",
+//	             "file_created", "processing_date", "description"},
+//	  "batches": [{
+//	    "header":  {"bsb", "account_number", "account_name",
+//	                "transaction_date", "amount", "indicator"},
+//	    "records": [{"bsb", "account_number", "account_name",
+//	                 "transaction_date", "amount", "indicator",
+//	                 "transaction_code", "description", "reference_number",
+//	                 "secondary_reference_number", "cheque_number"}],
+//	    "trailer": {"bsb", "account_number", "account_name",
+//	                "transaction_date", "amount", "indicator", "batch_type",
+//	                "reference_number", "total_debit_transactions",
+//	                "total_credit_transactions", "total_debit_amount",
+//	                "total_credit_amount"}
+//	  }],
+//	  "trailer": {"customer_number", "customer_name",
+//	              "total_debit_transactions", "total_credit_transactions",
+//	              "total_debit_amount", "total_credit_amount"}
+//	}
+//
+// Amounts are strings with two decimal places, e.g. "426.32", and dates are
+// ISO 8601 dates, e.g. "2012-07-31", or null when not set. Counts and
+// reference numbers are numbers and everything else is a string.
+
+type jsonFile struct {
+	Header  jsonFileHeader  `json:"header"`
+	Batches []jsonBatch     `json:"batches"`
+	Trailer jsonFileTrailer `json:"trailer"`
+}
+
+type jsonFileHeader struct {
+	CustomerNumber string   `json:"customer_number"`
+	CustomerName   string   `json:"customer_name"`
+	RemitterName   string   `json:"remitter_name"`
+	FileCreated    jsonDate `json:"file_created"`
+	ProcessingDate jsonDate `json:"processing_date"`
+	Description    string   `json:"description"`
+}
+
+type jsonBatch struct {
+	Header  jsonBatchHeader  `json:"header"`
+	Records []jsonRecord     `json:"records"`
+	Trailer jsonBatchTrailer `json:"trailer"`
+}
+
+type jsonBatchHeader struct {
+	BSBNumber       string     `json:"bsb"`
+	AccountNumber   string     `json:"account_number"`
+	AccountName     string     `json:"account_name"`
+	TransactionDate jsonDate   `json:"transaction_date"`
+	Amount          jsonAmount `json:"amount"`
+	Indicator       Indicator  `json:"indicator"`
+}
+
+type jsonRecord struct {
+	BSBNumber                string     `json:"bsb"`
+	AccountNumber            string     `json:"account_number"`
+	AccountName              string     `json:"account_name"`
+	TransactionDate          jsonDate   `json:"transaction_date"`
+	Amount                   jsonAmount `json:"amount"`
+	Indicator                Indicator  `json:"indicator"`
+	TransactionCode          string     `json:"transaction_code"`
+	Description              string     `json:"description"`
+	ReferenceNumber          int        `json:"reference_number"`
+	SecondaryReferenceNumber string     `json:"secondary_reference_number"`
+	ChequeNumber             string     `json:"cheque_number"`
+}
+
+type jsonBatchTrailer struct {
+	BSBNumber               string     `json:"bsb"`
+	AccountNumber           string     `json:"account_number"`
+	AccountName             string     `json:"account_name"`
+	TransactionDate         jsonDate   `json:"transaction_date"`
+	Amount                  jsonAmount `json:"amount"`
+	Indicator               Indicator  `json:"indicator"`
+	BatchType               BatchType  `json:"batch_type"`
+	ReferenceNumber         int        `json:"reference_number"`
+	TotalDebitTransactions  int        `json:"total_debit_transactions"`
+	TotalCreditTransactions int        `json:"total_credit_transactions"`
+	TotalDebitAmount        jsonAmount `json:"total_debit_amount"`
+	TotalCreditAmount       jsonAmount `json:"total_credit_amount"`
+}
+
+type jsonFileTrailer struct {
+	CustomerNumber          string     `json:"customer_number"`
+	CustomerName            string     `json:"customer_name"`
+	TotalDebitTransactions  int        `json:"total_debit_transactions"`
+	TotalCreditTransactions int        `json:"total_credit_transactions"`
+	TotalDebitAmount        jsonAmount `json:"total_debit_amount"`
+	TotalCreditAmount       jsonAmount `json:"total_credit_amount"`
+}
+
+// jsonDate is a date written as YYYY-MM-DD, null when zero
+type jsonDate time.Time
+
+const jsonDateFormat = "2006-01-02"
+
+func (d jsonDate) MarshalJSON() ([]byte, error) {
+	t := time.Time(d)
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Format(jsonDateFormat))
+}
+
+func (d *jsonDate) UnmarshalJSON(b []byte) error {
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == nil || *s == "" {
+		*d = jsonDate{}
+		return nil
+	}
+	t, err := time.Parse(jsonDateFormat, *s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDate(t)
+	return nil
+}
+
+// jsonAmount is a decimal written as a string with two decimal places
+type jsonAmount decimal.Decimal
+
+func (a jsonAmount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(decimal.Decimal(a).StringFixedBank(2))
+}
+
+func (a *jsonAmount) UnmarshalJSON(b []byte) error {
+	return (*decimal.Decimal)(a).UnmarshalJSON(b)
+}
+
+// MarshalJSON writes f in the documented JSON form
+func (f File) MarshalJSON() ([]byte, error) {
+	fh, ft := &f.FileHeader, &f.FileTrailer
+	doc := jsonFile{
+		Header: jsonFileHeader{
+			CustomerNumber: fh.CustomerNumber,
+			CustomerName:   fh.CustomerName,
+			RemitterName:   fh.RemitterName,
+			FileCreated:    jsonDate(fh.FileCreated),
+			ProcessingDate: jsonDate(fh.ProcessingDate),
+			Description:    fh.Description,
+		},
+		Batches: make([]jsonBatch, len(f.Batches)),
+		Trailer: jsonFileTrailer{
+			CustomerNumber:          ft.CustomerNumber,
+			CustomerName:            ft.CustomerName,
+			TotalDebitTransactions:  ft.TotalDebitTransactions,
+			TotalCreditTransactions: ft.TotalCreditTransactions,
+			TotalDebitAmount:        jsonAmount(ft.TotalDebitAmount),
+			TotalCreditAmount:       jsonAmount(ft.TotalCreditAmount),
+		},
+	}
+	for i, b := range f.Batches {
+		bh, bt := &b.BatchHeader, &b.BatchTrailer
+		jb := jsonBatch{
+			Header: jsonBatchHeader{
+				BSBNumber:       bh.BSBNumber,
+				AccountNumber:   bh.AccountNumber,
+				AccountName:     bh.AccountName,
+				TransactionDate: jsonDate(bh.TransactionDate),
+				Amount:          jsonAmount(bh.Amount),
+				Indicator:       bh.Indicator,
+			},
+			Records: make([]jsonRecord, len(b.Records)),
+			Trailer: jsonBatchTrailer{
+				BSBNumber:               bt.BSBNumber,
+				AccountNumber:           bt.AccountNumber,
+				AccountName:             bt.AccountName,
+				TransactionDate:         jsonDate(bt.TransactionDate),
+				Amount:                  jsonAmount(bt.Amount),
+				Indicator:               bt.Indicator,
+				BatchType:               bt.BatchType,
+				ReferenceNumber:         bt.ReferenceNumber,
+				TotalDebitTransactions:  bt.TotalDebitTransactions,
+				TotalCreditTransactions: bt.TotalCreditTransactions,
+				TotalDebitAmount:        jsonAmount(bt.TotalDebitAmount),
+				TotalCreditAmount:       jsonAmount(bt.TotalCreditAmount),
+			},
+		}
+		for k, r := range b.Records {
+			jb.Records[k] = jsonRecord{
+				BSBNumber:                r.BSBNumber,
+				AccountNumber:            r.AccountNumber,
+				AccountName:              r.AccountName,
+				TransactionDate:          jsonDate(r.TransactionDate),
+				Amount:                   jsonAmount(r.Amount),
+				Indicator:                r.Indicator,
+				TransactionCode:          r.TransactionCode,
+				Description:              r.Description,
+				ReferenceNumber:          r.ReferenceNumber,
+				SecondaryReferenceNumber: r.SecondaryReferenceNumber,
+				ChequeNumber:             r.ChequeNumber,
+			}
+		}
+		doc.Batches[i] = jb
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON reads f from the documented JSON form
+func (f *File) UnmarshalJSON(data []byte) error {
+	var doc jsonFile
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	*f = File{
+		FileHeader: FileHeader{
+			CustomerNumber: doc.Header.CustomerNumber,
+			CustomerName:   doc.Header.CustomerName,
+			RemitterName:   doc.Header.RemitterName,
+			FileCreated:    time.Time(doc.Header.FileCreated),
+			ProcessingDate: time.Time(doc.Header.ProcessingDate),
+			Description:    doc.Header.Description,
+		},
+		Batches: make([]Batch, len(doc.Batches)),
+		FileTrailer: FileTrailer{
+			recordType:              9,
+			CustomerNumber:          doc.Trailer.CustomerNumber,
+			CustomerName:            doc.Trailer.CustomerName,
+			TotalDebitTransactions:  doc.Trailer.TotalDebitTransactions,
+			TotalCreditTransactions: doc.Trailer.TotalCreditTransactions,
+			TotalDebitAmount:        decimal.Decimal(doc.Trailer.TotalDebitAmount),
+			TotalCreditAmount:       decimal.Decimal(doc.Trailer.TotalCreditAmount),
+		},
+	}
+	for i, jb := range doc.Batches {
+		b := Batch{
+			BatchHeader: BatchHeader{
+				recordType:      1,
+				BSBNumber:       jb.Header.BSBNumber,
+				AccountNumber:   jb.Header.AccountNumber,
+				AccountName:     jb.Header.AccountName,
+				TransactionDate: time.Time(jb.Header.TransactionDate),
+				Amount:          decimal.Decimal(jb.Header.Amount),
+				Indicator:       jb.Header.Indicator,
+			},
+			Records: make([]Record, len(jb.Records)),
+			BatchTrailer: BatchTrailer{
+				recordType:              7,
+				BSBNumber:               jb.Trailer.BSBNumber,
+				AccountNumber:           jb.Trailer.AccountNumber,
+				AccountName:             jb.Trailer.AccountName,
+				TransactionDate:         time.Time(jb.Trailer.TransactionDate),
+				Amount:                  decimal.Decimal(jb.Trailer.Amount),
+				Indicator:               jb.Trailer.Indicator,
+				BatchType:               jb.Trailer.BatchType,
+				ReferenceNumber:         jb.Trailer.ReferenceNumber,
+				TotalDebitTransactions:  jb.Trailer.TotalDebitTransactions,
+				TotalCreditTransactions: jb.Trailer.TotalCreditTransactions,
+				TotalDebitAmount:        decimal.Decimal(jb.Trailer.TotalDebitAmount),
+				TotalCreditAmount:       decimal.Decimal(jb.Trailer.TotalCreditAmount),
+			},
+		}
+		for k, r := range jb.Records {
+			b.Records[k] = Record{
+				recordType:               2,
+				BSBNumber:                r.BSBNumber,
+				AccountNumber:            r.AccountNumber,
+				AccountName:              r.AccountName,
+				TransactionDate:          time.Time(r.TransactionDate),
+				Amount:                   decimal.Decimal(r.Amount),
+				Indicator:                r.Indicator,
+				TransactionCode:          r.TransactionCode,
+				Description:              r.Description,
+				ReferenceNumber:          r.ReferenceNumber,
+				SecondaryReferenceNumber: r.SecondaryReferenceNumber,
+				ChequeNumber:             r.ChequeNumber,
+			}
+		}
+		f.Batches[i] = b
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package txn
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Align is the justification of a value within its field
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignRight
+)
+
+// FieldType says how the text of a field maps to a Go value
+type FieldType int
+
+const (
+	Alpha   FieldType = iota // string, trimmed when read
+	Numeric                  // int, blank filled fields read as zero
+	Amount                   // decimal.Decimal with two decimal places
+	Date                     // time.Time as YYYYMMDD
+)
+
+var (
+	ErrFieldOverflow = errors.New("txn: Value too wide for field")
+
+	errBlankField      = errors.New("field is blank")
+	errWrongLength     = errors.New("line is the wrong length")
+	errWrongRecordType = errors.New("line has the wrong record type")
+)
+
+// Field is a single fixed width column within a Layout
+type Field struct {
+	Name   string // name of the struct field holding the value
+	Start  int    // 0-based offset as in the pos comments on each type
+	Length int
+	Align  Align
+	Pad    byte // fill character, space if zero
+	Type   FieldType
+}
+
+// End is the offset just past the field
+func (f *Field) End() int {
+	return f.Start + f.Length
+}
+
+// Layout describes the columns of one kind of TXN line. The same Layout is
+// used to both decode and encode a line so the two can never disagree.
+type Layout struct {
+	Name       string // e.g. Record, used in errors
+	RecordType byte   // character in column 0 identifying the line
+	Width      int    // length of the line excluding the line ending
+	Err        error  // sentinel wrapped by decoding errors, e.g. ErrBadRecord
+	DateFormat string // time layout of Date fields, 20060102 if empty
+	Fields     []Field
+}
+
+// The layouts of the five TXN line types, taken from their struct tags
+var (
+	fileHeaderLayout   = mustLayout(FileHeader{}, ErrBadFileHeader)
+	batchHeaderLayout  = mustLayout(BatchHeader{}, ErrBadBatchHeader)
+	recordLayout       = mustLayout(Record{}, ErrBadRecord)
+	batchTrailerLayout = mustLayout(BatchTrailer{}, ErrBadBatchTrailer)
+	fileTrailerLayout  = mustLayout(FileTrailer{}, ErrBadFileTrailer)
+)
+
+// FileHeaderLayout returns a copy of the Layout of a FileHeader line.
+// Changing it has no effect on Readers and Writers.
+func FileHeaderLayout() *Layout {
+	return fileHeaderLayout.clone()
+}
+
+// BatchHeaderLayout returns a copy of the Layout of a BatchHeader line
+func BatchHeaderLayout() *Layout {
+	return batchHeaderLayout.clone()
+}
+
+// RecordLayout returns a copy of the Layout of a Record line
+func RecordLayout() *Layout {
+	return recordLayout.clone()
+}
+
+// BatchTrailerLayout returns a copy of the Layout of a BatchTrailer line
+func BatchTrailerLayout() *Layout {
+	return batchTrailerLayout.clone()
+}
+
+// FileTrailerLayout returns a copy of the Layout of a FileTrailer line
+func FileTrailerLayout() *Layout {
+	return fileTrailerLayout.clone()
+}
+
+// clone returns a copy of l that can be changed without affecting l
+func (l *Layout) clone() *Layout {
+	c := *l
+	c.Fields = append([]Field(nil), l.Fields...)
+	return &c
+}
+
+// Field returns the named field of the layout
+func (l *Layout) Field(name string) (Field, bool) {
+	for _, f := range l.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Decode unpacks line, with or without its line ending, into the struct
+// pointed to by v. Every field that fails to convert is reported.
+func (l *Layout) Decode(line string, v interface{}) error {
+	return l.decode(line, v, false)
+}
+
+func (l *Layout) decode(line string, v interface{}, lenient bool) error {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) != l.Width {
+		return &ParseError{RecordType: l.Name, Err: fmt.Errorf("%w: %w", l.Err, errWrongLength)}
+	}
+	if line[0] != l.RecordType {
+		return &ParseError{RecordType: l.Name, Err: fmt.Errorf("%w: %w", l.Err, errWrongRecordType)}
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	d := fieldReader{layout: l, line: line, lenient: lenient}
+	for _, f := range l.Fields {
+		fv := rv.FieldByName(f.Name)
+		switch f.Type {
+		case Alpha:
+			d.text(f, fv)
+		case Numeric:
+			fv.SetInt(int64(d.int(f)))
+		case Amount:
+			fv.Set(reflect.ValueOf(d.amount(f)))
+		case Date:
+			fv.Set(reflect.ValueOf(d.date(f)))
+		}
+	}
+	return d.errs.asError()
+}
+
+// Encode packs the struct pointed to by v into a line of exactly Width
+// characters, without a line ending. Text too long for its field is
+// truncated but numbers and amounts that don't fit are an error, as is text
+// its MarshalText rejects, such as an unknown Indicator.
+func (l *Layout) Encode(v interface{}) (string, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	line := []byte(strings.Repeat(" ", l.Width))
+	line[0] = l.RecordType
+
+	for _, f := range l.Fields {
+		var s string
+		fv := rv.FieldByName(f.Name)
+		switch f.Type {
+		case Alpha:
+			s = fv.String()
+			if m, ok := fv.Interface().(encoding.TextMarshaler); ok {
+				b, err := m.MarshalText()
+				if err != nil {
+					return "", l.invalid(f.Name, err)
+				}
+				s = string(b)
+			}
+			if len(s) > f.Length {
+				s = s[:f.Length]
+			}
+		case Numeric:
+			s = strconv.FormatInt(fv.Int(), 10)
+		case Amount:
+			s = fv.Interface().(decimal.Decimal).StringFixedBank(2)
+		case Date:
+			s = fv.Interface().(time.Time).Format(l.dateFormat())
+		}
+		if len(s) > f.Length {
+			return "", l.invalid(f.Name, fmt.Errorf("%w, got %q", ErrFieldOverflow, s))
+		}
+		copy(line[f.Start:f.End()], f.pad(s))
+	}
+	return string(line), nil
+}
+
+// pad justifies s within the field using its fill character
+func (f *Field) pad(s string) string {
+	pad := f.Pad
+	if pad == 0 {
+		pad = ' '
+	}
+	fill := strings.Repeat(string(pad), f.Length-len(s))
+	if f.Align == AlignRight {
+		return fill + s
+	}
+	return s + fill
+}
+
+func (l *Layout) dateFormat() string {
+	if l.DateFormat == "" {
+		return "20060102"
+	}
+	return l.DateFormat
+}
+
+// fieldError builds a *ParseError pointing at the named field
+func (l *Layout) fieldError(name string, err error) *ParseError {
+	f, _ := l.Field(name)
+	return &ParseError{
+		RecordType: l.Name,
+		Field:      l.Name + "." + name,
+		Start:      f.Start,
+		End:        f.End(),
+		Err:        err,
+	}
+}
+
+// invalid builds a *FieldError for the named field
+func (l *Layout) invalid(name string, err error) *FieldError {
+	return &FieldError{RecordType: l.Name, Field: l.Name + "." + name, Err: err}
+}
+
+// parseError positions a *FieldError of l on its columns of a line read
+func (l *Layout) parseError(fe *FieldError) *ParseError {
+	return l.fieldError(strings.TrimPrefix(fe.Field, l.Name+"."), fe.Err)
+}
+
+// fieldReader unpacks the columns of a single line, collecting a *ParseError
+// for every field that fails to convert. In lenient mode failed conversions
+// silently decode as the zero value instead.
+type fieldReader struct {
+	layout  *Layout
+	line    string
+	lenient bool
+	errs    ParseErrors
+}
+
+func (d *fieldReader) str(f Field) string {
+	return strings.TrimSpace(d.line[f.Start:f.End()])
+}
+
+// text decodes an alpha column into fv, through its UnmarshalText if it has
+// one. Blank columns are left as the zero value.
+func (d *fieldReader) text(f Field, fv reflect.Value) {
+	s := d.str(f)
+	u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler)
+	if !ok {
+		fv.SetString(s)
+		return
+	}
+	fv.SetString("")
+	if s == "" {
+		return
+	}
+	if err := u.UnmarshalText([]byte(s)); err != nil {
+		d.fail(f, err)
+	}
+}
+
+// int decodes a numeric column, blank filled columns are taken as zero
+func (d *fieldReader) int(f Field) int {
+	s := d.str(f)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		d.fail(f, err)
+	}
+	return v
+}
+
+func (d *fieldReader) amount(f Field) decimal.Decimal {
+	s := d.str(f)
+	if s == "" {
+		d.fail(f, errBlankField)
+		return decimal.Decimal{}
+	}
+	v, err := decimal.NewFromString(s)
+	if err != nil {
+		d.fail(f, err)
+	}
+	return v
+}
+
+func (d *fieldReader) date(f Field) time.Time {
+	v, err := time.Parse(d.layout.dateFormat(), d.str(f))
+	if err != nil {
+		d.fail(f, err)
+	}
+	return v
+}
+
+// fail records a conversion error unless reading leniently
+func (d *fieldReader) fail(f Field, err error) {
+	if d.lenient {
+		return
+	}
+	d.errs = append(d.errs, d.layout.fieldError(f.Name, fmt.Errorf("%w: %w", d.layout.Err, err)))
+}
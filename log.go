@@ -0,0 +1,30 @@
+package txn
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+)
+
+// discardHandler drops every log record without formatting it
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+// logger returns l, or a Logger discarding everything if l is nil
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return discardLogger
+	}
+	return l
+}
+
+// eventName names the line type of e, e.g. Record
+func eventName(e Event) string {
+	return reflect.TypeOf(e).Name()
+}
@@ -0,0 +1,251 @@
+package txn
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// layouts caches the Layout of every struct type seen by LayoutOf
+var layouts sync.Map // map[reflect.Type]*Layout
+
+var (
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// Marshal encodes the struct v as a single fixed width line, without a line
+// ending, using the Layout described by its txn struct tags.
+func Marshal(v interface{}) ([]byte, error) {
+	l, err := layoutOf(v)
+	if err != nil {
+		return nil, err
+	}
+	line, err := l.Encode(v)
+	return []byte(line), err
+}
+
+// Unmarshal decodes a single fixed width line into the struct pointed to by
+// v using the Layout described by its txn struct tags.
+func Unmarshal(line []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("txn: Unmarshal needs a non-nil pointer, got %T", v)
+	}
+	l, err := layoutOf(v)
+	if err != nil {
+		return err
+	}
+	return l.Decode(string(line), v)
+}
+
+// LayoutOf returns the Layout described by the txn struct tags of v, which
+// must be a struct or pointer to one. Fields of embedded structs are
+// included so a type embedding Record can extend it with extra columns.
+//
+// One field, usually unexported, identifies the line and sets its width:
+//
+//	recordType int `txn:"record=2,width=168"`
+//
+// Every other tagged field, which must be exported, is a column:
+//
+//	Amount decimal.Decimal `txn:"pos=60,len=16,align=right,type=decimal"`
+//
+// where align is left (the default) or right, pad is the fill character
+// (space by default) and type is one of string, int, decimal or date. When
+// type is left out it follows from the Go type of the field.
+//
+// The Layout returned is a copy, changing it doesn't affect Marshal or
+// Unmarshal.
+func LayoutOf(v interface{}) (*Layout, error) {
+	l, err := layoutOf(v)
+	if err != nil {
+		return nil, err
+	}
+	return l.clone(), nil
+}
+
+// layoutOf returns the cached Layout of v, building it the first time
+func layoutOf(v interface{}) (*Layout, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("txn: %T is not a struct", v)
+	}
+	if l, ok := layouts.Load(t); ok {
+		return l.(*Layout), nil
+	}
+
+	l := &Layout{Name: t.Name(), Err: ErrBadLine}
+	if err := l.addFields(t); err != nil {
+		return nil, err
+	}
+	if l.RecordType == 0 {
+		return nil, fmt.Errorf("txn: %s has no field tagged with record=", t)
+	}
+	if err := l.check(); err != nil {
+		return nil, err
+	}
+	actual, _ := layouts.LoadOrStore(t, l)
+	return actual.(*Layout), nil
+}
+
+// mustLayout derives the layout of one of the built in line types
+func mustLayout(v interface{}, err error) *Layout {
+	l, lerr := LayoutOf(v)
+	if lerr != nil {
+		panic(lerr)
+	}
+	l.Err = err
+	return l
+}
+
+func (l *Layout) addFields(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("txn")
+		if !ok {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				if err := l.addFields(sf.Type); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+
+		f := Field{Name: sf.Name, Type: typeOf(sf.Type)}
+		isRecord := false
+		for _, opt := range strings.Split(tag, ",") {
+			key, value, _ := strings.Cut(opt, "=")
+			var err error
+			switch key {
+			case "record":
+				if len(value) != 1 {
+					err = fmt.Errorf("record must be a single character")
+				} else {
+					isRecord = true
+					l.RecordType = value[0]
+				}
+			case "width":
+				l.Width, err = strconv.Atoi(value)
+			case "pos":
+				f.Start, err = strconv.Atoi(value)
+			case "len":
+				f.Length, err = strconv.Atoi(value)
+			case "align":
+				switch value {
+				case "left":
+					f.Align = AlignLeft
+				case "right":
+					f.Align = AlignRight
+				default:
+					err = fmt.Errorf("unknown align %q", value)
+				}
+			case "pad":
+				if len(value) != 1 {
+					err = fmt.Errorf("pad must be a single character")
+				} else {
+					f.Pad = value[0]
+				}
+			case "type":
+				switch value {
+				case "string":
+					f.Type = Alpha
+				case "int":
+					f.Type = Numeric
+				case "decimal":
+					f.Type = Amount
+				case "date":
+					f.Type = Date
+				default:
+					err = fmt.Errorf("unknown type %q", value)
+				}
+			default:
+				err = fmt.Errorf("unknown option %q", key)
+			}
+			if err != nil {
+				return fmt.Errorf("txn: bad tag on %s.%s: %w", t.Name(), sf.Name, err)
+			}
+		}
+		if isRecord {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return fmt.Errorf("txn: %s.%s must be exported to be a column", t.Name(), sf.Name)
+		}
+		if !f.fits(sf.Type) {
+			return fmt.Errorf("txn: %s.%s of type %s can't hold a %s field", t.Name(), sf.Name, sf.Type, f.Type)
+		}
+		l.Fields = append(l.Fields, f)
+	}
+	return nil
+}
+
+// check makes sure the fields lie within the line and don't overlap
+func (l *Layout) check() error {
+	if l.Width < 1 {
+		return fmt.Errorf("txn: %s has no width", l.Name)
+	}
+	used := make([]string, l.Width)
+	used[0] = "record type"
+	for _, f := range l.Fields {
+		if f.Start < 0 || f.Length < 1 || f.End() > l.Width {
+			return fmt.Errorf("txn: %s.%s lies outside the %d character line", l.Name, f.Name, l.Width)
+		}
+		for i := f.Start; i < f.End(); i++ {
+			if used[i] != "" {
+				return fmt.Errorf("txn: %s.%s overlaps %s at column %d", l.Name, f.Name, used[i], i)
+			}
+			used[i] = f.Name
+		}
+	}
+	return nil
+}
+
+// typeOf picks the FieldType that suits a Go type when no type is tagged
+func typeOf(t reflect.Type) FieldType {
+	switch {
+	case t == decimalType:
+		return Amount
+	case t == timeType:
+		return Date
+	case t.Kind() == reflect.Int:
+		return Numeric
+	}
+	return Alpha
+}
+
+// fits reports whether values of the field can be held in Go type t
+func (f *Field) fits(t reflect.Type) bool {
+	switch f.Type {
+	case Amount:
+		return t == decimalType
+	case Date:
+		return t == timeType
+	case Numeric:
+		return t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64
+	}
+	return t.Kind() == reflect.String
+}
+
+func (t FieldType) String() string {
+	switch t {
+	case Numeric:
+		return "int"
+	case Amount:
+		return "decimal"
+	case Date:
+		return "date"
+	}
+	return "string"
+}
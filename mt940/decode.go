@@ -0,0 +1,277 @@
+package mt940
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/17twenty/txn"
+	"github.com/17twenty/txn/internal/importer"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	accountRegEx   = regexp.MustCompile(`^(\d{3})-?(\d{3})[/ ]?(\d{1,9})$`)
+	numberRegEx    = regexp.MustCompile(`^(\d+)(?:/\d+)?$`)
+	balanceRegEx   = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)$`)
+	statementRegEx = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)[A-Z]?(\d+,\d*)([NFS][A-Z0-9]{3})(.*?)(?://(.*))?$`)
+)
+
+// Decoder reads MT940 messages from an input stream
+type Decoder struct {
+	// Currency expected of every statement, AUD if empty. Others are
+	// reported as TXN amounts have no currency.
+	Currency string
+	r        io.Reader
+}
+
+// NewDecoder returns a new Decoder that reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// field is a single tagged field of a message and the line it started on
+type field struct {
+	tag, value string
+	line       int
+}
+
+// Decode reads every message into a Batch of a File, the inverse of
+// Encoder.Encode. Anything the TXN fields can't hold is reported as a
+// warning in the ValidationReport, with the line it was found on.
+func (d *Decoder) Decode() (*txn.File, txn.ValidationReport, error) {
+	messages, err := split(d.r)
+	if err != nil {
+		return nil, txn.ValidationReport{}, err
+	}
+
+	s := statement{currency: d.Currency}
+	if s.currency == "" {
+		s.currency = "AUD"
+	}
+	for i, m := range messages {
+		s.decode(i, m)
+	}
+
+	s.File.FileHeader.Description = "ACCOUNT TRANSACTIONS"
+	if n := len(s.File.Batches); n > 0 {
+		date := s.File.Batches[n-1].BatchHeader.TransactionDate
+		s.File.FileHeader.FileCreated, s.File.FileHeader.ProcessingDate = date, date
+	}
+	s.File.SetTrailers()
+	return &s.File, s.Report, s.err
+}
+
+// split reads the fields of each message, a message starting at :20:.
+// SWIFT block headers and the lines ending each message are skipped.
+func split(r io.Reader) ([][]field, error) {
+	var messages [][]field
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if i := strings.Index(line, ":20:"); i > 0 && strings.HasPrefix(line, "{") {
+			line = line[i:] // {1:...}{2:...}{4::20:...
+		}
+		tag, value, ok := "", "", false
+		if strings.HasPrefix(line, ":") {
+			tag, value, ok = strings.Cut(line[1:], ":")
+		}
+		switch {
+		case ok && tag == "20":
+			messages = append(messages, []field{{tag, value, n}})
+		case line == "-" || line == "-}" || strings.HasPrefix(line, "{"):
+		case len(messages) == 0:
+			// Anything before the first message
+		case ok:
+			m := &messages[len(messages)-1]
+			*m = append(*m, field{tag, value, n})
+		default:
+			m := messages[len(messages)-1]
+			m[len(m)-1].value += "\n" + line
+		}
+	}
+	return messages, sc.Err()
+}
+
+// statement accumulates the File and report while decoding
+type statement struct {
+	importer.Import
+	currency string
+	err      error
+}
+
+// at is the position of f, reported by its tag
+func at(f field, batch, record int) txn.Finding {
+	return txn.Finding{Line: f.line, Batch: batch, Record: record, Field: ":" + f.tag + ":"}
+}
+
+func (s *statement) fail(f field, batch int, err error) {
+	s.Fail(at(f, batch, -1), err)
+	if s.err == nil {
+		s.err = fmt.Errorf("mt940: line %d: %w", f.line, err)
+	}
+}
+
+func (s *statement) decode(i int, fields []field) {
+	var (
+		b       txn.Batch
+		opening decimal.Decimal
+		last    *txn.Record // awaiting its :86:
+		closed  bool        // seen the closing balance
+	)
+	for _, f := range fields {
+		record := len(b.Records) - 1
+		switch f.tag {
+		case "20":
+		case "25":
+			if m := accountRegEx.FindStringSubmatch(f.value); m != nil {
+				b.BatchHeader.BSBNumber = m[1] + "-" + m[2]
+				b.BatchHeader.AccountNumber = m[3]
+			} else {
+				b.BatchHeader.AccountName, _ = importer.Truncate(txn.BatchHeaderLayout(), "AccountName", f.value)
+				s.Warn(at(f, i, -1), fmt.Errorf("%w: account %q isn't a BSB and account number, kept as the AccountName", txn.ErrNotRepresentable, f.value))
+			}
+		case "28C":
+			if m := numberRegEx.FindStringSubmatch(f.value); m != nil {
+				b.BatchTrailer.ReferenceNumber, _ = strconv.Atoi(m[1])
+			}
+		case "60F", "60M":
+			amount, _, err := s.balance(f, i)
+			if err != nil {
+				s.fail(f, i, err)
+			}
+			opening = amount
+		case "62F", "62M":
+			amount, date, err := s.balance(f, i)
+			if err != nil {
+				s.fail(f, i, err)
+				continue
+			}
+			b.BatchHeader.Amount, b.BatchHeader.Indicator = amount.Abs(), txn.Credit
+			if amount.Sign() < 0 {
+				b.BatchHeader.Indicator = txn.Debit
+			}
+			b.BatchHeader.TransactionDate = date
+			b.BatchTrailer.TransactionDate, b.BatchTrailer.BatchType = date, txn.BatchTXN
+			closed = true
+		case "61":
+			r, err := s.statementLine(f, i, len(b.Records))
+			if err != nil {
+				s.fail(f, i, err)
+				last = nil
+				continue
+			}
+			b.Records = append(b.Records, r)
+			last = &b.Records[len(b.Records)-1]
+		case "86":
+			if last == nil || closed {
+				s.Warn(at(f, i, -1), fmt.Errorf("%w: statement information %q", txn.ErrNotRepresentable, f.value))
+				continue
+			}
+			desc := strings.Join(strings.Fields(f.value), " ")
+			last.Description = s.Fit(at(f, i, record), txn.RecordLayout(), "Description", desc)
+			last = nil
+		default:
+			s.Warn(at(f, i, -1), fmt.Errorf("%w: field :%s: %q", txn.ErrNotRepresentable, f.tag, f.value))
+		}
+	}
+
+	for k := range b.Records {
+		r := &b.Records[k]
+		r.BSBNumber, r.AccountNumber = b.BatchHeader.BSBNumber, b.BatchHeader.AccountNumber
+		r.AccountName = b.BatchHeader.AccountName
+	}
+	b.BatchHeader.AccountName, _ = importer.Truncate(txn.BatchHeaderLayout(), "AccountName", b.BatchHeader.AccountName)
+	if closed {
+		s.Reconcile(at(fields[0], i, -1), &b, opening)
+	}
+	s.File.Batches = append(s.File.Batches, b)
+}
+
+// balance decodes a :60: or :62: balance as a signed amount
+func (s *statement) balance(f field, batch int) (decimal.Decimal, time.Time, error) {
+	m := balanceRegEx.FindStringSubmatch(f.value)
+	if m == nil {
+		return decimal.Decimal{}, time.Time{}, fmt.Errorf("bad balance %q", f.value)
+	}
+	date, err := time.Parse(dateFormat, m[2])
+	if err != nil {
+		return decimal.Decimal{}, time.Time{}, err
+	}
+	amount, err := parseAmount(m[4])
+	if m[1] == "D" {
+		amount = amount.Neg()
+	}
+	if m[3] != s.currency {
+		s.Warn(at(f, batch, -1), fmt.Errorf("%w: currency %s", txn.ErrNotRepresentable, m[3]))
+	}
+	return amount, date, err
+}
+
+// statementLine decodes a :61: field into a Record. The transaction type is
+// taken as the TXN transaction code when it is N followed by a code
+// Import.TransactionCode keeps, the reference for the account owner as the
+// ReferenceNumber when numeric and the reference of the bank as the
+// ChequeNumber.
+func (s *statement) statementLine(f field, batch, record int) (txn.Record, error) {
+	first, supplementary, _ := strings.Cut(f.value, "\n")
+	m := statementRegEx.FindStringSubmatch(first)
+	if m == nil {
+		return txn.Record{}, fmt.Errorf("bad statement line %q", first)
+	}
+	date, err := time.Parse(dateFormat, m[1])
+	if err != nil {
+		return txn.Record{}, err
+	}
+	amount, err := parseAmount(m[4])
+	if err != nil {
+		return txn.Record{}, err
+	}
+
+	r := txn.Record{TransactionDate: date, Amount: amount, Indicator: txn.Credit}
+	switch m[3] {
+	case "D":
+		r.Indicator = txn.Debit
+	case "RC":
+		// Reversal of a credit
+		r.Indicator = txn.Debit
+		fallthrough
+	case "RD":
+		s.Warn(at(f, batch, record), fmt.Errorf("%w: reversal mark %s", txn.ErrNotRepresentable, m[3]))
+	}
+	if m[2] != "" && m[2] != m[1][2:] {
+		s.Warn(at(f, batch, record), fmt.Errorf("%w: entry date %s", txn.ErrNotRepresentable, m[2]))
+	}
+
+	code := m[5]
+	if code[0] == 'N' {
+		code = strings.TrimLeft(code[1:], "0")
+		for len(code) < 2 {
+			code = "0" + code
+		}
+	}
+	r.TransactionCode = s.TransactionCode(at(f, batch, record), code, r.Indicator)
+
+	ref := strings.TrimSpace(m[6])
+	if n, err := strconv.Atoi(ref); err == nil && len(ref) <= 10 {
+		r.ReferenceNumber = n
+	} else if ref != "NONREF" && ref != "" {
+		r.SecondaryReferenceNumber = s.Fit(at(f, batch, record), txn.RecordLayout(), "SecondaryReferenceNumber", ref)
+	}
+	if bank := strings.TrimSpace(m[7]); bank != "" {
+		r.ChequeNumber = s.Fit(at(f, batch, record), txn.RecordLayout(), "ChequeNumber", bank)
+	}
+	if supplementary = strings.TrimSpace(supplementary); supplementary != "" {
+		s.Warn(at(f, batch, record), fmt.Errorf("%w: supplementary details %q", txn.ErrNotRepresentable, supplementary))
+	}
+	return r, nil
+}
+
+// parseAmount reads an amount with a decimal comma
+func parseAmount(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.Replace(s, ",", ".", 1))
+}
@@ -0,0 +1,193 @@
+// Package mt940 renders TXN statements as SWIFT MT940 customer statement
+// messages.
+package mt940
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/17twenty/txn"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	dateFormat  = "060102"
+	lineLength  = 65 // longest line of a :86: field
+	infoLines   = 6  // most lines in a :86: field
+	refLength   = 16
+	maxNumber   = 99999 // largest 5n statement number of a :28C: field
+	lineEnding  = "\r\n"
+	endOfRecord = "-"
+)
+
+// Encoder writes MT940 messages to an output stream
+type Encoder struct {
+	// Currency of every statement, AUD if empty
+	Currency string
+	w        *bufio.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes f as one MT940 message per Batch, each ended by a line
+// holding a single "-". The :60F: opening balance is the closing balance
+// less the net of the BatchTrailer and the :62F: closing balance is the
+// BatchHeader Amount. Each Record becomes a :61: line with its
+// Description in the following :86:.
+func (e *Encoder) Encode(f *txn.File) error {
+	for i := range f.Batches {
+		e.message(i, &f.Batches[i], f.FileHeader.FileCreated)
+	}
+	return e.w.Flush()
+}
+
+func (e *Encoder) message(i int, b *txn.Batch, created time.Time) {
+	ccy := e.Currency
+	if ccy == "" {
+		ccy = "AUD"
+	}
+	h, t := &b.BatchHeader, &b.BatchTrailer
+
+	opening, closing := b.Balances()
+	start := h.TransactionDate
+	for _, r := range b.Records {
+		if r.TransactionDate.Before(start) {
+			start = r.TransactionDate
+		}
+	}
+	number := t.ReferenceNumber
+	if number <= 0 {
+		number = i + 1
+	}
+	// Only 5 digits fit, so larger numbers wrap round to start again at 1
+	number = (number-1)%maxNumber + 1
+
+	e.field("20", created.Format(dateFormat)+"-"+strconv.Itoa(i+1))
+	e.field("25", X(h.BSBNumber+"/"+h.AccountNumber))
+	e.field("28C", strconv.Itoa(number))
+	e.field("60F", balance(opening, start, ccy))
+	for k := range b.Records {
+		r := &b.Records[k]
+		e.field("61", statementLine(r))
+		if info := Wrap(X(r.Description), lineLength, infoLines); len(info) > 0 {
+			e.field("86", strings.Join(info, lineEnding))
+		}
+	}
+	e.field("62F", balance(closing, h.TransactionDate, ccy))
+	e.w.WriteString(endOfRecord + lineEnding)
+}
+
+func (e *Encoder) field(tag, value string) {
+	e.w.WriteString(":" + tag + ":" + value + lineEnding)
+}
+
+// statementLine builds a :61: field, value date, entry date, mark, amount,
+// transaction type, the ReferenceNumber as the reference for the account
+// owner and the ChequeNumber as the reference of the bank. The type is N
+// followed by the TXN transaction code.
+func statementLine(r *txn.Record) string {
+	var b strings.Builder
+	b.WriteString(r.TransactionDate.Format(dateFormat))
+	b.WriteString(r.TransactionDate.Format("0102"))
+	b.WriteString(mark(r.Indicator == txn.Credit))
+	b.WriteString(formatAmount(r.Amount))
+
+	code := strings.TrimSpace(r.TransactionCode)
+	for len(code) < 3 {
+		code = "0" + code
+	}
+	b.WriteString("N" + X(code[:3]))
+
+	ref := "NONREF"
+	if r.ReferenceNumber != 0 {
+		ref = strconv.Itoa(r.ReferenceNumber)
+	}
+	b.WriteString(truncate(ref, refLength))
+	if c := strings.TrimSpace(X(r.ChequeNumber)); c != "" {
+		b.WriteString("//" + truncate(c, refLength))
+	}
+	return b.String()
+}
+
+func balance(amount decimal.Decimal, date time.Time, ccy string) string {
+	return mark(amount.Sign() >= 0) + date.Format(dateFormat) + ccy + formatAmount(amount.Abs())
+}
+
+// formatAmount writes an amount with a decimal comma, e.g. 1210,00
+func formatAmount(amount decimal.Decimal) string {
+	return strings.Replace(amount.StringFixedBank(2), ".", ",", 1)
+}
+
+func mark(credit bool) string {
+	if credit {
+		return "C"
+	}
+	return "D"
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// X restricts s to the SWIFT X character set, replacing anything else
+// with a space
+func X(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("/-?:().,'+ ", r):
+			return r
+		}
+		return ' '
+	}, s)
+}
+
+// Wrap splits s into at most lines lines of at most width characters,
+// breaking between words where possible and dropping anything beyond. Runs
+// of spaces are collapsed and no line starts with ':' or '-', which would
+// be mistaken for a new field or the end of the message. Nothing is
+// returned when width or lines isn't positive.
+func Wrap(s string, width, lines int) []string {
+	if width <= 0 || lines <= 0 {
+		return nil
+	}
+	var out []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for word != "" {
+			if line == "" && (word[0] == ':' || word[0] == '-') {
+				word = "." + word
+			}
+			switch {
+			case line == "" && len(word) <= width:
+				line, word = word, ""
+			case line != "" && len(line)+1+len(word) <= width:
+				line, word = line+" "+word, ""
+			case line == "":
+				// Longer than a whole line so it has to be split
+				line, word = word[:width], word[width:]
+				fallthrough
+			default:
+				out = append(out, line)
+				line = ""
+			}
+			if len(out) == lines {
+				return out
+			}
+		}
+	}
+	if line != "" {
+		out = append(out, line)
+	}
+	return out
+}
@@ -0,0 +1,151 @@
+package mt940
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/17twenty/txn"
+	"github.com/17twenty/txn/internal/sample"
+)
+
+func TestEncode(t *testing.T) {
+	file := sample.Read(t)
+	file.Batches[0].Records[9].ChequeNumber = "001234"
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(file); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	lines := strings.Split(buf.String(), "\r\n")
+	expected := []string{
+		":20:120801-1",
+		":25:182-222/117867898",
+		":28C:1",
+		":60F:C120702AUD1637,50",
+		":61:1207020702D2721,78N013245397",
+		":86:DDR GL481 Tower Australia",
+		":61:1207060706C1210,00N050NONREF",
+		":86:TEST TRANS SIMPSON DESERT O",
+	}
+	if !reflect.DeepEqual(lines[:len(expected)], expected) {
+		t.Fatalf("Failure - expected\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+	if lines[4+2*9] != ":61:1207310731D9000,00N013NONREF//001234" {
+		t.Fatal("Expected a cheque number as the bank reference but got", lines[4+2*9])
+	}
+	if end := lines[len(lines)-3:]; end[0] != ":62F:C120731AUD426,32" || end[1] != "-" || end[2] != "" {
+		t.Fatalf("Failure - unexpected end of message %q", end)
+	}
+
+	// Statement numbers are 5 digits at most, wrapping round past 99999
+	for number, want := range map[int]string{99999: ":28C:99999", 100000: ":28C:1", 123456: ":28C:23457"} {
+		file.Batches[0].BatchTrailer.ReferenceNumber = number
+		buf.Reset()
+		NewEncoder(&buf).Encode(file)
+		if lines = strings.Split(buf.String(), "\r\n"); lines[2] != want {
+			t.Fatal("Expected '", want, "' but got", lines[2])
+		}
+	}
+}
+
+func TestX(t *testing.T) {
+	if s := X("Smith & Sons_Pty {Ltd} O'Brien 100%"); s != "Smith   Sons Pty  Ltd  O'Brien 100 " {
+		t.Fatalf("Failure - unexpected %q", s)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	got := Wrap("PAYMENT   FOR -INVOICE 12345 :REF ABCDEFGHIJKLMNOP", 10, 6)
+	expected := []string{"PAYMENT", "FOR", ".-INVOICE", "12345 :REF", "ABCDEFGHIJ", "KLMNOP"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Failure - expected %q but got %q", expected, got)
+	}
+	if got = Wrap(":A :B :C :D", 2, 3); !reflect.DeepEqual(got, []string{".:", "A", ".:"}) {
+		t.Fatalf("Failure - unexpected %q", got)
+	}
+	if got = Wrap(strings.Repeat("A", 25), 10, 6); !reflect.DeepEqual(got, []string{"AAAAAAAAAA", "AAAAAAAAAA", "AAAAA"}) {
+		t.Fatalf("Failure - unexpected %q", got)
+	}
+	for _, limits := range [][2]int{{0, 6}, {-1, 6}, {10, 0}, {10, -1}} {
+		if got = Wrap("PAYMENT FOR INVOICE", limits[0], limits[1]); got != nil {
+			t.Fatalf("Failure - expected nothing for %v but got %q", limits, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	original := sample.Read(t)
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(original); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	file, report, err := NewDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal("error decoding", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Fatal("Expected nothing to be lost but got", report.Findings)
+	}
+	if len(file.Batches) != 1 || len(file.Batches[0].Records) != 10 {
+		t.Fatalf("Failure - expected 1 batch of 10 records but got %+v", file.Batches)
+	}
+	b, want := file.Batches[0], original.Batches[0]
+	h := b.BatchHeader
+	if h.BSBNumber != "182-222" || h.AccountNumber != "117867898" || !h.Amount.Equal(want.BatchHeader.Amount) || h.Indicator != txn.Credit {
+		t.Fatalf("Failure - unexpected batch header %+v", h)
+	}
+	r := b.Records[0]
+	if !r.Amount.Equal(want.Records[0].Amount) || r.Indicator != txn.Debit || r.TransactionCode != "13" ||
+		r.ReferenceNumber != 245397 || r.Description != "DDR GL481 Tower Australia" || r.AccountNumber != "117867898" {
+		t.Fatalf("Failure - unexpected record %+v", r)
+	}
+	if bt := b.BatchTrailer; !bt.Amount.Equal(want.BatchTrailer.Amount) || bt.Indicator != txn.Debit || bt.TotalCreditTransactions != 5 {
+		t.Fatalf("Failure - unexpected batch trailer %+v", bt)
+	}
+}
+
+func TestDecodeReport(t *testing.T) {
+	msg := strings.Join([]string{
+		"{1:F01BANKAU2SAXXX0000000000}{4:",
+		":20:STMT",
+		":25:NL91ABNA0417164300",
+		":28C:7/1",
+		":60F:C120730EUR100,00",
+		":61:1207310730D25,00FCHGNONREF//B1",
+		"BANK CHARGES",
+		":86:A description far too long for the forty characters of TXN",
+		":62F:C120731EUR80,00",
+		"-}",
+	}, "\r\n")
+	file, report, err := NewDecoder(strings.NewReader(msg)).Decode()
+	if err != nil {
+		t.Fatal("error decoding", err)
+	}
+	r := file.Batches[0].Records[0]
+	for _, f := range report.Findings {
+		if f.Line == 6 && f.Record != 0 {
+			t.Fatal("Expected findings for the first record but got", f)
+		}
+	}
+	if r.TransactionCode != "13" || r.ChequeNumber != "B1" || r.Description != "A description far too long for the forty" {
+		t.Fatalf("Failure - unexpected record %+v", r)
+	}
+	var lost, cut, totals int
+	for _, f := range report.Warnings() {
+		switch {
+		case errors.Is(f.Err, txn.ErrNotRepresentable):
+			lost++
+		case errors.Is(f.Err, txn.ErrTruncated):
+			cut++
+		case errors.Is(f.Err, txn.ErrTotalsMismatch):
+			totals++
+		}
+	}
+	// The account, both currencies, entry date, transaction type and
+	// supplementary details
+	if lost != 6 || cut != 1 || totals != 1 || len(report.Errors()) != 0 {
+		t.Fatal("Expected lost, truncated and unbalanced values but got", report.Findings)
+	}
+}
@@ -0,0 +1,184 @@
+// Package ofx renders TXN statements as OFX 2.x bank statement responses so
+// they can be loaded into accounting packages that only import OFX.
+package ofx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/17twenty/txn"
+)
+
+const (
+	header     = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n"
+	ofxHeader  = `<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+	dateFormat = "20060102"
+	nameLength = 32 // longest NAME allowed in a STMTTRN
+)
+
+// Encoder writes OFX documents to an output stream
+type Encoder struct {
+	// Currency of every statement, AUD if empty
+	Currency string
+	// AccountType of every account, CHECKING if empty
+	AccountType string
+	w           io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+type document struct {
+	XMLName xml.Name    `xml:"OFX"`
+	SignOn  signOn      `xml:"SIGNONMSGSRSV1>SONRS"`
+	Bank    []stmtTrnRs `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+type status struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type signOn struct {
+	Status   status `xml:"STATUS"`
+	DTServer string `xml:"DTSERVER"`
+	Language string `xml:"LANGUAGE"`
+}
+
+type stmtTrnRs struct {
+	TrnUID string `xml:"TRNUID"`
+	Status status `xml:"STATUS"`
+	StmtRs stmtRs `xml:"STMTRS"`
+}
+
+type stmtRs struct {
+	CurDef   string   `xml:"CURDEF"`
+	Account  account  `xml:"BANKACCTFROM"`
+	TranList tranList `xml:"BANKTRANLIST"`
+	Ledger   balance  `xml:"LEDGERBAL"`
+}
+
+type account struct {
+	BankID   string `xml:"BANKID"`
+	AcctID   string `xml:"ACCTID"`
+	AcctType string `xml:"ACCTTYPE"`
+}
+
+type tranList struct {
+	DTStart string    `xml:"DTSTART"`
+	DTEnd   string    `xml:"DTEND"`
+	Trans   []stmtTrn `xml:"STMTTRN"`
+}
+
+type stmtTrn struct {
+	TrnType  string `xml:"TRNTYPE"`
+	DTPosted string `xml:"DTPOSTED"`
+	TrnAmt   string `xml:"TRNAMT"`
+	FITID    string `xml:"FITID"`
+	CheckNum string `xml:"CHECKNUM,omitempty"`
+	Name     string `xml:"NAME,omitempty"`
+	Memo     string `xml:"MEMO,omitempty"`
+}
+
+type balance struct {
+	BalAmt string `xml:"BALAMT"`
+	DTAsOf string `xml:"DTASOF"`
+}
+
+// Encode writes f as a single OFX document holding a STMTRS per Batch, for
+// the account of its BatchHeader. The ledger balance is the BatchHeader
+// Amount and each Record becomes a STMTTRN.
+func (e *Encoder) Encode(f *txn.File) error {
+	doc := document{
+		SignOn: signOn{
+			Status:   status{Severity: "INFO"},
+			DTServer: f.FileHeader.FileCreated.Format(dateFormat),
+			Language: "ENG",
+		},
+	}
+	for i := range f.Batches {
+		doc.Bank = append(doc.Bank, stmtTrnRs{
+			TrnUID: strconv.Itoa(i + 1),
+			Status: status{Severity: "INFO"},
+			StmtRs: e.statement(&f.Batches[i]),
+		})
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "%s%s%s\n", header, ofxHeader, out)
+	return err
+}
+
+func (e *Encoder) statement(b *txn.Batch) stmtRs {
+	h := &b.BatchHeader
+	_, closing := b.Balances()
+	s := stmtRs{
+		CurDef: e.Currency,
+		Account: account{
+			BankID:   strings.ReplaceAll(h.BSBNumber, "-", ""),
+			AcctID:   h.AccountNumber,
+			AcctType: e.AccountType,
+		},
+		Ledger: balance{
+			BalAmt: closing.StringFixedBank(2),
+			DTAsOf: h.TransactionDate.Format(dateFormat),
+		},
+	}
+	if s.CurDef == "" {
+		s.CurDef = "AUD"
+	}
+	if s.Account.AcctType == "" {
+		s.Account.AcctType = "CHECKING"
+	}
+
+	start, end := h.TransactionDate, h.TransactionDate
+	seen := map[string]int{}
+	for _, r := range b.Records {
+		if r.TransactionDate.Before(start) {
+			start = r.TransactionDate
+		}
+		if r.TransactionDate.After(end) {
+			end = r.TransactionDate
+		}
+		s.TranList.Trans = append(s.TranList.Trans, transaction(&r, seen))
+	}
+	s.TranList.DTStart = start.Format(dateFormat)
+	s.TranList.DTEnd = end.Format(dateFormat)
+	return s
+}
+
+// transaction converts a Record to a STMTTRN. The FITID is the date and
+// ReferenceNumber of the record, suffixed by how many records before it in
+// the batch share both so it stays unique within the account.
+func transaction(r *txn.Record, seen map[string]int) stmtTrn {
+	date := r.TransactionDate.Format(dateFormat)
+	id := date + "-" + strconv.Itoa(r.ReferenceNumber)
+	seen[id]++
+
+	t := stmtTrn{
+		TrnType:  "CREDIT",
+		DTPosted: date,
+		TrnAmt:   r.SignedAmount().StringFixedBank(2),
+		FITID:    id + "-" + strconv.Itoa(seen[id]),
+		// Descriptions are often padded subfields, e.g. a payee and reference
+		Name: strings.Join(strings.Fields(r.Description), " "),
+	}
+	if r.Indicator == txn.Debit {
+		t.TrnType = "DEBIT"
+	}
+	if n, err := strconv.Atoi(r.ChequeNumber); err == nil && n != 0 {
+		t.CheckNum = strconv.Itoa(n)
+	}
+	if len(t.Name) > nameLength {
+		t.Name, t.Memo = strings.TrimSpace(t.Name[:nameLength]), r.Description
+	}
+	return t
+}
@@ -0,0 +1,59 @@
+package ofx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/17twenty/txn/internal/sample"
+)
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(sample.Read(t)); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>`+"\n"+`<?OFX OFXHEADER="200" VERSION="211"`) {
+		t.Fatal("Expected the OFX 2 headers but got", out[:100])
+	}
+
+	var doc document
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("error parsing output", err)
+	}
+	if len(doc.Bank) != 1 {
+		t.Fatal("Expected 1 statement but got", len(doc.Bank))
+	}
+	s := doc.Bank[0].StmtRs
+	if s.Account != (account{"182222", "117867898", "CHECKING"}) || s.CurDef != "AUD" {
+		t.Fatalf("Failure - unexpected account %+v", s.Account)
+	}
+	if s.Ledger != (balance{"426.32", "20120731"}) {
+		t.Fatalf("Failure - unexpected ledger balance %+v", s.Ledger)
+	}
+	if s.TranList.DTStart != "20120702" || s.TranList.DTEnd != "20120731" || len(s.TranList.Trans) != 10 {
+		t.Fatalf("Failure - unexpected transaction list %+v", s.TranList)
+	}
+
+	first := s.TranList.Trans[0]
+	if first.TrnType != "DEBIT" || first.TrnAmt != "-2721.78" || first.FITID != "20120702-245397-1" || first.Name != "DDR GL481 Tower Australia" || first.Memo != "" {
+		t.Fatalf("Failure - unexpected transaction %+v", first)
+	}
+	if c := s.TranList.Trans[1]; c.TrnType != "CREDIT" || c.TrnAmt != "1210.00" {
+		t.Fatalf("Failure - unexpected transaction %+v", c)
+	}
+	last := s.TranList.Trans[9]
+	if len(last.Name) > 32 || last.Memo != "TEST ACCOUNT PAYMENT FOR     Loan to HCA" {
+		t.Fatalf("Failure - expected a long description in MEMO but got %+v", last)
+	}
+
+	ids := map[string]bool{}
+	for _, tr := range s.TranList.Trans {
+		if ids[tr.FITID] {
+			t.Fatal("Expected unique FITIDs but got", tr.FITID, "twice")
+		}
+		ids[tr.FITID] = true
+	}
+}
@@ -3,7 +3,8 @@ package txn
 import (
 	"bufio"
 	"io"
-	"log"
+	"log/slog"
+	"strings"
 )
 
 // A Reader reads records from an TXN file.
@@ -11,10 +12,25 @@ import (
 // As returned by NewReader, a Reader expects input conforming to spec.
 // The Header and Trailer fields expose details about the underlying item
 type Reader struct {
+	// Lenient decodes fields that fail to convert, such as a corrupt amount
+	// or date, as their zero value rather than returning an error
+	Lenient bool
+	// SkipVerify turns off checking the BatchTrailer and FileTrailer totals
+	// against the records actually read
+	SkipVerify bool
+	// Dialect of the file, Macquarie's when left as the zero value
+	Dialect Dialect
+	// Logger receives structured debug and warning logs, nil discards them
+	Logger      *slog.Logger
 	FileHeader  FileHeader
 	Batch       []Batch
 	FileTrailer FileTrailer
 	r           *bufio.Reader
+	batches     int   // batch headers seen so far by Next
+	line        int   // line number of the last line read
+	offset      int64 // byte offset of the next line
+	batchTotals tally // records since the last BatchHeader
+	fileTotals  tally // records since the start of the file
 }
 
 // Batch describes a TXN batch, a file can have multiple batches
@@ -24,6 +40,19 @@ type Batch struct {
 	BatchTrailer BatchTrailer
 }
 
+// Event is a single decoded line of a TXN file as returned by Reader.Next.
+// The concrete type is one of FileHeader, BatchHeader, Record, BatchTrailer
+// or FileTrailer.
+type Event interface {
+	event()
+}
+
+func (FileHeader) event()   {}
+func (BatchHeader) event()  {}
+func (Record) event()       {}
+func (BatchTrailer) event() {}
+func (FileTrailer) event()  {}
+
 // NewReader returns a new Reader that reads from r.
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
@@ -34,63 +63,145 @@ func NewReader(r io.Reader) *Reader {
 // ReadAll reads all the remaining records from r.
 func (r *Reader) ReadAll() (batch []Batch, err error) {
 	for {
-		err = r.readRecordOrHeaderOrTrailer()
+		var e Event
+		e, err = r.Next()
 		if err == io.EOF {
 			err = nil // ReadAll is happy - not erroneous
 			return r.Batch, err
 		}
 		if err != nil {
-			log.Println("readRecordOrHeaderOrTrailer", err)
 			break
 		}
+
+		switch v := e.(type) {
+		case BatchHeader:
+			r.current().BatchHeader = v
+		case Record:
+			b := r.current()
+			b.Records = append(b.Records, v)
+		case BatchTrailer:
+			r.current().BatchTrailer = v
+		}
 	}
 	return r.Batch, err
 }
 
-func (r *Reader) readRecordOrHeaderOrTrailer() error {
-	var (
-		record Record
-		batch  Batch
-	)
+// current returns the Batch of the last batch header line read. A header
+// that failed to decode is stood in for by an empty one so the lines after
+// it still land in its batch.
+func (r *Reader) current() *Batch {
+	for len(r.Batch) < r.batches {
+		r.Batch = append(r.Batch, Batch{})
+	}
+	return &r.Batch[len(r.Batch)-1]
+}
+
+// Next reads and decodes the next line from r, returning it as an Event.
+// Unlike ReadAll nothing is accumulated on the Reader other than the
+// FileHeader and FileTrailer, so memory use stays constant regardless of
+// the size of the file. At the end of the input Next returns io.EOF.
+func (r *Reader) Next() (Event, error) {
+	e, err := r.next()
+	switch {
+	case err == nil:
+		logger(r.Logger).Debug("txn: read line", "line", r.line, "record_type", eventName(e), "batch", r.batches-1)
+	case err != io.EOF:
+		recordType := ""
+		if errs := parseErrors(err); errs != nil {
+			recordType = errs[0].RecordType
+		}
+		logger(r.Logger).Warn("txn: bad line", "line", r.line, "record_type", recordType, "batch", r.batches-1, "err", err)
+	}
+	return e, err
+}
+
+func (r *Reader) next() (Event, error) {
 	b, err := r.r.ReadByte()
 	if err != nil || r.r.UnreadByte() != nil {
-		return err
+		return nil, err
 	}
 
 	// We'll always want a line
 	line, err := r.r.ReadString('\n')
 	if err != nil && err != io.EOF {
 		// Could be a trailer - there's no newline there. Look for EOF?
-		log.Println("Didn't get a line")
-		return err
+		return nil, err
 	}
+	r.line++
+	offset := r.offset
+	r.offset += int64(len(line))
 
 	switch b {
 	case '0':
-		err = r.FileHeader.Read(line)
+		if err = r.FileHeader.read(line, r.Lenient, &r.Dialect); err == nil {
+			return r.FileHeader, nil
+		}
 	case '1':
-		if err = batch.BatchHeader.Read(line); err == nil {
-			r.Batch = append(r.Batch, batch)
+		var h BatchHeader
+		// A bad header still starts a batch, the lines after it belong to it
+		r.batches++
+		r.batchTotals = tally{}
+		if err = h.read(line, r.Lenient, &r.Dialect); err == nil {
+			return h, nil
 		}
 	case '2':
-		err = record.Read(line)
-		// No point appending garbage
-		if err == nil {
-			if record.IsValid() {
-				r.Batch[len(r.Batch)-1].Records = append(r.Batch[len(r.Batch)-1].Records, record)
-			} else {
-				err = ErrInvalidRecord
-			}
+		var record Record
+		if r.batches == 0 {
+			return nil, r.parseError(&ParseError{RecordType: "Record", Err: ErrNoBatch}, line, offset)
+		}
+		// No point returning garbage
+		if err = record.read(line, r.Lenient, &r.Dialect); err == nil {
+			r.batchTotals.add(record)
+			r.fileTotals.add(record)
+			return record, nil
 		}
 	case '7':
-		if err = batch.BatchTrailer.Read(line); err == nil {
-			r.Batch[len(r.Batch)-1].BatchTrailer = batch.BatchTrailer
+		var t BatchTrailer
+		if r.batches == 0 {
+			return nil, r.parseError(&ParseError{RecordType: "BatchTrailer", Err: ErrNoBatch}, line, offset)
+		}
+		if err = t.read(line, r.Lenient, &r.Dialect); err == nil {
+			if r.SkipVerify || r.Dialect.OmitBatchTotals {
+				return t, nil
+			}
+			if err = r.batchTotals.verifyBatch(r.batches-1, &t); err == nil {
+				return t, nil
+			}
+			err = &ParseError{RecordType: "BatchTrailer", Err: err}
 		}
 	case '9':
-		err = r.FileTrailer.Read(line)
+		if err = r.FileTrailer.read(line, r.Lenient, &r.Dialect); err == nil {
+			if r.SkipVerify || r.Dialect.OmitBatchTotals {
+				return r.FileTrailer, nil
+			}
+			if err = r.fileTotals.verifyFile(&r.FileTrailer); err == nil {
+				return r.FileTrailer, nil
+			}
+			err = &ParseError{RecordType: "FileTrailer", Err: err}
+		}
 	default:
 		err = ErrUnexpectedRecordType
 	}
 
+	return nil, r.parseError(err, line, offset)
+}
+
+// parseError attaches the position of line within the file to err
+func (r *Reader) parseError(err error, line string, offset int64) error {
+	var errs ParseErrors
+	switch e := err.(type) {
+	case ParseErrors:
+		errs = e
+	case *ParseError:
+		errs = ParseErrors{e}
+	default:
+		pe := &ParseError{Err: err}
+		err, errs = pe, ParseErrors{pe}
+	}
+	for _, pe := range errs {
+		pe.Line = r.line
+		pe.Offset = offset
+		pe.Raw = strings.TrimRight(line, "\r\n")
+	}
 	return err
 }
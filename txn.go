@@ -4,20 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"regexp"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 const (
-	Debit    = "DR"
-	Credit   = "CR"
-	BatchTXN = "ST"
-	BatchPAY = "SP"
+	Debit  Indicator = "DR"
+	Credit Indicator = "CR"
+
+	BatchTXN BatchType = "ST"
+	BatchPAY BatchType = "SP"
 )
 
 var (
@@ -29,93 +27,91 @@ var (
 	ErrBadRecord            = errors.New("txn: Bad Record prevented reading")
 	ErrBadBatchTrailer      = errors.New("txn: Bad Batch Trailer prevented reading")
 	ErrBadFileTrailer       = errors.New("txn: Bad File Trailer prevented reading")
+	ErrBadLine              = errors.New("txn: Bad line prevented reading")
 	ErrUnexpectedRecordType = errors.New("txn: Unexpected record type, can decode 0,1 and 7 only")
-
-	bsbNumberRegEx = regexp.MustCompile(`^\d{3}-\d{3}$`)
+	ErrNoBatch              = errors.New("txn: Record or Batch Trailer found before any Batch Header")
+	ErrBatchOpen            = errors.New("txn: Batch already open, call EndBatch first")
+	ErrBatchNotOpen         = errors.New("txn: No batch open, call BeginBatch first")
+	ErrNoBalancing          = errors.New("txn: Dialect requires a balancing line but Writer.Balancing is not set")
+	ErrTotalsMismatch       = errors.New("txn: Trailer totals don't match the records read")
+	ErrMissingFileHeader    = errors.New("txn: File Header must be the first line")
+	ErrMissingFileTrailer   = errors.New("txn: File Trailer missing from end of file")
+	ErrMissingBatchTrailer  = errors.New("txn: Batch has no Batch Trailer")
+	ErrTrailingData         = errors.New("txn: Data found after the File Trailer")
+	ErrEmptyBatch           = errors.New("txn: Batch has no records")
+	ErrZeroAmount           = errors.New("txn: Record amount is zero")
+	ErrBadBSB               = errors.New("txn: BSB must be in the format 000-000")
+	ErrUnknownBSB           = errors.New("txn: BSB not found in the directory")
+	ErrClosedBSB            = errors.New("txn: BSB is closed")
+	ErrBadAccountNumber     = errors.New("txn: Account number must be 1-9 digits")
+	ErrWrongDirection       = errors.New("txn: Transaction code is for the opposite direction to the Indicator")
+	ErrUnknownIndicator     = errors.New("txn: Indicator must be DR or CR")
+	ErrUnknownBatchType     = errors.New("txn: Batch type must be ST or SP")
+	ErrNotRepresentable     = errors.New("txn: Imported value has no TXN field to hold it")
+	ErrTruncated            = errors.New("txn: Value truncated to fit its field")
+
+	bsbNumberRegEx     = regexp.MustCompile(`^\d{3}-\d{3}$`)
+	accountNumberRegEx = regexp.MustCompile(`^\d{1,9}$`)
 )
 
-func padRight(str, pad string, length int) string {
-	for {
-		str += pad
-		if len(str) > length {
-			return str[0:length]
-		}
-	}
-}
-
-func spaces(howMany int) string {
-	return padRight("", " ", howMany)
-}
-
 // FileHeader TXN file header
 type FileHeader struct {
-	recordType     int       // pos 1      - always zero
-	CustomerNumber string    // pos 1-10   - left justified e.g. 00123456
-	CustomerName   string    // pos 10-45  - left justified and blank filled. e.g. AAA LEGAL SERVICES
-	RemitterName   string    // pos 45-64  - left justified and blank filled. e.g. ‘MACQUARIE BANK
-	FileCreated    time.Time // pos 64-72  - YYYYMMDD and zero filled
-	ProcessingDate time.Time // pos 72-80  - YYYYMMDD and zero filled
-	Description    string    // pos 80-100 - left justified and blank filled. e.g. ACCOUNT TRANSACTIONS or DEFT PAYMENTS
+	recordType     int       `txn:"record=0,width=170"`            // pos 1      - always zero
+	CustomerNumber string    `txn:"pos=1,len=8,align=right,pad=0"` // pos 1-9    - right justified and zero filled e.g. 00123456
+	CustomerName   string    `txn:"pos=9,len=35"`                  // pos 9-44   - left justified and blank filled. e.g. AAA LEGAL SERVICES
+	RemitterName   string    `txn:"pos=44,len=20"`                 // pos 44-64  - left justified and blank filled. e.g. ‘MACQUARIE BANK
+	FileCreated    time.Time `txn:"pos=64,len=8,type=date"`        // pos 64-72  - YYYYMMDD and zero filled
+	ProcessingDate time.Time `txn:"pos=72,len=8,type=date"`        // pos 72-80  - YYYYMMDD and zero filled
+	Description    string    `txn:"pos=80,len=20"`                 // pos 80-100 - left justified and blank filled. e.g. ACCOUNT TRANSACTIONS or DEFT PAYMENTS
 	// Space filled from 100-170. Spaces between every gap for a total 170 characters
 }
 
+// Read decodes a FileHeader line, failing if any field can't be converted.
 func (h *FileHeader) Read(l string) error {
-	if len(l) != 171 && len(l) != 172 { // 170 + '\n' || 170 + '\r\n'
-		log.Println("TXN: Header expected 170, got", len(l))
-		return ErrBadFileHeader
-	}
-	// Just read it all back in and unpack
-	h.recordType, _ = strconv.Atoi(strings.TrimSpace(l[0:1]))
-	h.CustomerNumber = strings.TrimSpace(l[1:10])
-	h.CustomerName = strings.TrimSpace(l[10:45])
-	h.RemitterName = strings.TrimSpace(l[45:64])
-	h.FileCreated, _ = time.Parse("20060102", strings.TrimSpace(l[64:72]))
-	h.ProcessingDate, _ = time.Parse("20060102", strings.TrimSpace(l[72:80]))
-	h.Description = strings.TrimSpace(l[80:100])
-	return nil
+	return h.read(l, false, new(Dialect))
+}
+
+func (h *FileHeader) read(l string, lenient bool, d *Dialect) error {
+	h.recordType = 0
+	return d.Layout(fileHeaderLayout).decode(l, h, lenient)
 }
 
 // BatchHeader TXN batch header per batch, multiple batches possible
 type BatchHeader struct {
-	recordType      int             // pos 1       - always one
-	BSBNumber       string          // pos 1-8     - in the format 182-222
-	AccountNumber   string          // pos 8-17    - e.g. 116217011
-	AccountName     string          // pos 17-52   - left justified and blank filled. e.g. ‘DEMO ACCOUNT NUMBER 1’
-	TransactionDate time.Time       // pos 52-60   - YYYYMMDD and zero filled
-	Amount          decimal.Decimal // pos 60-76   - Right justified and blank filled. e.g. 123456.78
-	Indicator       string          // pos 76-78   - Debit/Credit - DR or CR
+	recordType      int             `txn:"record=1,width=170"`                     // pos 1       - always one
+	BSBNumber       string          `txn:"pos=1,len=7"`                            // pos 1-8     - in the format 182-222
+	AccountNumber   string          `txn:"pos=8,len=9,align=right"`                // pos 8-17    - right justified and blank filled. e.g. 116217011
+	AccountName     string          `txn:"pos=17,len=35"`                          // pos 17-52   - left justified and blank filled. e.g. ‘DEMO ACCOUNT NUMBER 1’
+	TransactionDate time.Time       `txn:"pos=52,len=8,type=date"`                 // pos 52-60   - YYYYMMDD and zero filled
+	Amount          decimal.Decimal `txn:"pos=60,len=16,align=right,type=decimal"` // pos 60-76   - Right justified and blank filled. e.g. 123456.78
+	Indicator       Indicator       `txn:"pos=76,len=2"`                           // pos 76-78   - Debit/Credit - DR or CR
 	// Space filled from 78-170. Spaces between every gap for a total 170 characters
 }
 
+// Read decodes a BatchHeader line, failing if any field can't be converted.
 func (h *BatchHeader) Read(l string) error {
-	if len(l) != 171 && len(l) != 172 { // 170 + '\n' || 170 + '\r\n'
-		log.Println("TXN: Header expected 170, got", len(l))
-		return ErrBadBatchHeader
-	}
-	// Just read it all back in and unpack
-	h.BSBNumber = strings.TrimSpace(l[1:8])
-	h.AccountNumber = strings.TrimSpace(l[8:17])
-	h.AccountName = strings.TrimSpace(l[17:52])
-	h.TransactionDate, _ = time.Parse("20060102", strings.TrimSpace(l[52:60]))
-	h.Amount, _ = decimal.NewFromString(strings.TrimSpace(l[60:76]))
-	h.Indicator = strings.TrimSpace(l[76:78])
-	return nil
+	return h.read(l, false, new(Dialect))
+}
+
+func (h *BatchHeader) read(l string, lenient bool, d *Dialect) error {
+	h.recordType = 1
+	return d.Layout(batchHeaderLayout).decode(l, h, lenient)
 }
 
 // Record ..
 type Record struct {
-	recordType               int             // pos 1       - always two
-	BSBNumber                string          // pos 1-8     - in the format 182-222
-	AccountNumber            string          // pos 8-17    - e.g. 116217011
-	AccountName              string          // pos 17-52   - left justified and blank filled. e.g. ‘DEMO ACCOUNT NUMBER 1’
-	TransactionDate          time.Time       // pos 52-60   - YYYYMMDD and zero filled
-	Amount                   decimal.Decimal // pos 60-76   - Right justified and blank filled. e.g. 123456.78
-	Indicator                string          // pos 76-78   - Debit/Credit - DR or CR
-	TransactionCode          string          // pos 78-80   - Either 13, debit or 50, credit.
-	Description              string          // pos 80-120  - left justified and blank filled.
-	ReferenceNumber          int             // pos 120-130 - left justified and blank filled.
-	SecondaryReferenceNumber string          // pos 130-140 - not utilised for General products
-	ChequeNumber             string          // pos 140-148 - left justified and blank filled
+	recordType               int             `txn:"record=2,width=168"`                     // pos 1       - always two
+	BSBNumber                string          `txn:"pos=1,len=7"`                            // pos 1-8     - in the format 182-222
+	AccountNumber            string          `txn:"pos=8,len=9,align=right"`                // pos 8-17    - right justified and blank filled. e.g. 116217011
+	AccountName              string          `txn:"pos=17,len=35"`                          // pos 17-52   - left justified and blank filled. e.g. ‘DEMO ACCOUNT NUMBER 1’
+	TransactionDate          time.Time       `txn:"pos=52,len=8,type=date"`                 // pos 52-60   - YYYYMMDD and zero filled
+	Amount                   decimal.Decimal `txn:"pos=60,len=16,align=right,type=decimal"` // pos 60-76   - Right justified and blank filled. e.g. 123456.78
+	Indicator                Indicator       `txn:"pos=76,len=2"`                           // pos 76-78   - Debit/Credit - DR or CR
+	TransactionCode          string          `txn:"pos=78,len=2"`                           // pos 78-80   - e.g. 13, debit or 50, credit. See LookupTransactionCode
+	Description              string          `txn:"pos=80,len=40"`                          // pos 80-120  - left justified and blank filled.
+	ReferenceNumber          int             `txn:"pos=120,len=10,type=int"`                // pos 120-130 - left justified and blank filled.
+	SecondaryReferenceNumber string          `txn:"pos=130,len=10"`                         // pos 130-140 - not utilised for General products
+	ChequeNumber             string          `txn:"pos=140,len=8"`                          // pos 140-148 - left justified and blank filled
 	// Space filled from 148-168. Spaces between every gap for a total 168 characters
 }
 
@@ -130,198 +126,137 @@ func (r *Record) IsValid() bool {
 	default:
 		return false
 	}
+	if !matchesIndicator(r.TransactionCode, r.Indicator) {
+		return false
+	}
 
 	// BSB validation
 	return bsbNumberRegEx.MatchString(r.BSBNumber)
 }
 
-func (r *Record) Read(l string) error {
-	if len(l) != 169 && len(l) != 170 { // 168 + '\n' || 168 + '\r\n'
-		return ErrBadRecord
+// invalidFields returns an error for each field that fails IsValid or
+// carries a transaction code the dialect doesn't allow
+func (r *Record) invalidFields(d *Dialect) FieldErrors {
+	var errs FieldErrors
+	if r.Indicator != Debit && r.Indicator != Credit {
+		errs = append(errs, recordLayout.invalid("Indicator", ErrInvalidRecord))
+	}
+	if !bsbNumberRegEx.MatchString(r.BSBNumber) {
+		errs = append(errs, recordLayout.invalid("BSBNumber", ErrInvalidRecord))
+	}
+	if !d.allowsCode(r.TransactionCode) {
+		errs = append(errs, recordLayout.invalid("TransactionCode", ErrInvalidRecord))
+	} else if (r.Indicator == Debit || r.Indicator == Credit) && !matchesIndicator(r.TransactionCode, r.Indicator) {
+		errs = append(errs, recordLayout.invalid("TransactionCode", fmt.Errorf("%w: %w", ErrInvalidRecord, ErrWrongDirection)))
 	}
-	r.recordType, _ = strconv.Atoi(strings.TrimSpace(l[0:1]))
-	// Just read it all back in and unpack
-	r.BSBNumber = strings.TrimSpace(l[1:8])
-	r.AccountNumber = strings.TrimSpace(l[8:17])
-	r.AccountName = strings.TrimSpace(l[17:52])
-	r.TransactionDate, _ = time.Parse("20060102", strings.TrimSpace(l[52:60]))
-	r.Amount, _ = decimal.NewFromString(strings.TrimSpace(l[60:76]))
-	r.Indicator = strings.TrimSpace(l[76:78])
-	r.TransactionCode = strings.TrimSpace(l[78:80])
-	r.Description = strings.TrimSpace(l[80:120])
-	r.ReferenceNumber, _ = strconv.Atoi(strings.TrimSpace(l[120:130]))
-	r.SecondaryReferenceNumber = strings.TrimSpace(l[130:140])
-	r.ChequeNumber = strings.TrimSpace(l[140:148])
+	return errs
+}
 
-	if !r.IsValid() {
-		return ErrInvalidRecord
+// Read decodes a Record line, failing if any field can't be converted or
+// the record isn't valid.
+func (r *Record) Read(l string) error {
+	return r.read(l, false, new(Dialect))
+}
+
+func (r *Record) read(l string, lenient bool, d *Dialect) error {
+	r.recordType = 2
+	errs := parseErrors(d.Layout(recordLayout).decode(l, r, lenient))
+	if len(errs) == 1 && errs[0].Field == "" {
+		// The whole line is bad so there's nothing to validate
+		return errs[0]
+	}
+	for _, fe := range r.invalidFields(d) {
+		if prev := errs.field(fe.Field); prev != nil {
+			// Failed to decode as well as being invalid
+			prev.Err = fmt.Errorf("%w: %w", ErrInvalidRecord, prev.Err)
+			continue
+		}
+		errs = append(errs, recordLayout.parseError(fe))
 	}
-	return nil
+	return errs.asError()
 }
 
 // FileTrailer in TXN file
 type FileTrailer struct {
-	recordType              int             // pos 1      - always nine
-	CustomerNumber          string          // pos 1-9   - left justified e.g. 00123456
-	CustomerName            string          // pos 9-44  - left justified and blank filled. e.g. AAA LEGAL SERVICES
-	TotalDebitTransactions  int             // pos 44-50 - Right justified and blank filled. Total number of debits in file.
-	TotalCreditTransactions int             // pos 50-56 - Right justified and blank filled. Total number of credits in file.
-	TotalDebitAmount        decimal.Decimal // pos 56-72 - Right justified and blank filled. Total value of debits in file.
-	TotalCreditAmount       decimal.Decimal // pos 72-88 - Right justified and blank filled. Total value of credits in file.
+	recordType              int             `txn:"record=9,width=170"`                     // pos 1      - always nine
+	CustomerNumber          string          `txn:"pos=1,len=8,align=right,pad=0"`          // pos 1-9   - right justified and zero filled e.g. 00123456
+	CustomerName            string          `txn:"pos=9,len=35"`                           // pos 9-44  - left justified and blank filled. e.g. AAA LEGAL SERVICES
+	TotalDebitTransactions  int             `txn:"pos=44,len=6,align=right,type=int"`      // pos 44-50 - Right justified and blank filled. Total number of debits in file.
+	TotalCreditTransactions int             `txn:"pos=50,len=6,align=right,type=int"`      // pos 50-56 - Right justified and blank filled. Total number of credits in file.
+	TotalDebitAmount        decimal.Decimal `txn:"pos=56,len=16,align=right,type=decimal"` // pos 56-72 - Right justified and blank filled. Total value of debits in file.
+	TotalCreditAmount       decimal.Decimal `txn:"pos=72,len=16,align=right,type=decimal"` // pos 72-88 - Right justified and blank filled. Total value of credits in file.
 	// Space filled from 88-170. Spaces between every gap for a total 170 characters
 }
 
+// Read decodes a FileTrailer line, failing if any field can't be converted.
 func (t *FileTrailer) Read(l string) error {
-	if len(l) != 171 && len(l) != 172 { // 170 + '\n' || 170 + '\r\n'
-		log.Println("TXN: Trailer expected 171, got", len(l))
-		return ErrBadFileTrailer
-	}
-	// Just read it all back in and unpack
-	t.recordType, _ = strconv.Atoi(strings.TrimSpace(l[0:1]))
-
-	t.CustomerNumber = strings.TrimSpace(l[1:9])
-	t.CustomerName = strings.TrimSpace(l[9:44])
-
-	t.TotalDebitTransactions, _ = strconv.Atoi(strings.TrimSpace(l[44:50]))
-	t.TotalCreditTransactions, _ = strconv.Atoi(strings.TrimSpace(l[50:56]))
-
-	t.TotalDebitAmount, _ = decimal.NewFromString(strings.TrimSpace(l[56:72]))
-	t.TotalCreditAmount, _ = decimal.NewFromString(strings.TrimSpace(l[72:88]))
+	return t.read(l, false, new(Dialect))
+}
 
-	return nil
+func (t *FileTrailer) read(l string, lenient bool, d *Dialect) error {
+	t.recordType = 9
+	return d.Layout(fileTrailerLayout).decode(l, t, lenient)
 }
 
 // BatchTrailer TXN batch trailer per batch, multiple batches possible
 type BatchTrailer struct {
-	recordType              int             // pos 1       - always two
-	BSBNumber               string          // pos 1-8     - in the format 182-222
-	AccountNumber           string          // pos 8-17    - e.g. 116217011
-	AccountName             string          // pos 17-52   - left justified and blank filled. e.g. ‘DEMO ACCOUNT NUMBER 1’
-	TransactionDate         time.Time       // pos 52-60   - YYYYMMDD and zero filled
-	Amount                  decimal.Decimal // pos 60-76   - Right justified and blank filled. e.g. 123456.78
-	Indicator               string          // pos 76-78   - Debit/Credit - DR or CR
-	BatchType               string          // pos 78-80   - Either ST, txn or SP, pay.
-	ReferenceNumber         int             // pos 80-86 - left justified and blank filled.
-	TotalDebitTransactions  int             // pos 86-92 - Right justified and blank filled. Total number of debits in file.
-	TotalCreditTransactions int             // pos 92-98 - Right justified and blank filled. Total number of credits in file.
-	TotalDebitAmount        decimal.Decimal // pos 98-114 - Right justified and blank filled. Total value of debits in file.
-	TotalCreditAmount       decimal.Decimal // pos 114-130 - Right justified and blank filled. Total value of credits in file.
+	recordType              int             `txn:"record=7,width=170"`                      // pos 1       - always two
+	BSBNumber               string          `txn:"pos=1,len=7"`                             // pos 1-8     - in the format 182-222
+	AccountNumber           string          `txn:"pos=8,len=9,align=right"`                 // pos 8-17    - right justified and blank filled. e.g. 116217011
+	AccountName             string          `txn:"pos=17,len=35"`                           // pos 17-52   - left justified and blank filled. e.g. ‘DEMO ACCOUNT NUMBER 1’
+	TransactionDate         time.Time       `txn:"pos=52,len=8,type=date"`                  // pos 52-60   - YYYYMMDD and zero filled
+	Amount                  decimal.Decimal `txn:"pos=60,len=16,align=right,type=decimal"`  // pos 60-76   - Right justified and blank filled. e.g. 123456.78
+	Indicator               Indicator       `txn:"pos=76,len=2"`                            // pos 76-78   - Debit/Credit - DR or CR
+	BatchType               BatchType       `txn:"pos=78,len=2"`                            // pos 78-80   - Either ST, txn or SP, pay.
+	ReferenceNumber         int             `txn:"pos=80,len=6,align=right,pad=0,type=int"` // pos 80-86 - right justified and zero filled.
+	TotalDebitTransactions  int             `txn:"pos=86,len=6,align=right,type=int"`       // pos 86-92 - Right justified and blank filled. Total number of debits in file.
+	TotalCreditTransactions int             `txn:"pos=92,len=6,align=right,type=int"`       // pos 92-98 - Right justified and blank filled. Total number of credits in file.
+	TotalDebitAmount        decimal.Decimal `txn:"pos=98,len=16,align=right,type=decimal"`  // pos 98-114 - Right justified and blank filled. Total value of debits in file.
+	TotalCreditAmount       decimal.Decimal `txn:"pos=114,len=16,align=right,type=decimal"` // pos 114-130 - Right justified and blank filled. Total value of credits in file.
 	// Space filled from 130-170. Spaces between every gap for a total 170 characters
 }
 
+// Read decodes a BatchTrailer line, failing if any field can't be converted.
 func (t *BatchTrailer) Read(l string) error {
-	if len(l) != 171 && len(l) != 172 { // 170 + '\n' || 170 + '\r\n'
-		log.Println("TXN: Batch Trailer expected 170, got", len(l))
-		return ErrBadBatchTrailer
-	}
-	// Just read it all back in and unpack
-	t.recordType, _ = strconv.Atoi(strings.TrimSpace(l[0:1]))
-
-	t.BSBNumber = strings.TrimSpace(l[1:8])
-	t.AccountNumber = strings.TrimSpace(l[8:17])
-	t.AccountName = strings.TrimSpace(l[17:52])
-	t.TransactionDate, _ = time.Parse("20060102", strings.TrimSpace(l[52:60]))
-	t.Amount, _ = decimal.NewFromString(strings.TrimSpace(l[60:76]))
-	t.Indicator = strings.TrimSpace(l[76:78])
-	t.BatchType = strings.TrimSpace(l[78:80])
-	t.ReferenceNumber, _ = strconv.Atoi(strings.TrimSpace(l[80:86]))
-
-	t.TotalDebitTransactions, _ = strconv.Atoi(strings.TrimSpace(l[86:92]))
-	t.TotalCreditTransactions, _ = strconv.Atoi(strings.TrimSpace(l[92:98]))
-
-	t.TotalDebitAmount, _ = decimal.NewFromString(strings.TrimSpace(l[98:114]))
-	t.TotalCreditAmount, _ = decimal.NewFromString(strings.TrimSpace(l[114:130]))
+	return t.read(l, false, new(Dialect))
+}
 
-	return nil
+func (t *BatchTrailer) read(l string, lenient bool, d *Dialect) error {
+	t.recordType = 7
+	return d.Layout(batchTrailerLayout).decode(l, t, lenient)
 }
 
-func (t *BatchTrailer) Write(w io.Writer) {
-	tempStr := fmt.Sprintf(
-		"%d%7.7s%9.9s%-35.35s%8.8s%16.16s%2s%2s%06.6d%6.1d%6.1d%16.16s%16.16s%s",
-		t.recordType,
-		t.BSBNumber,
-		t.AccountNumber,
-		t.AccountName,
-		t.TransactionDate.Format("20060102"),
-		t.Amount.StringFixedBank(2),
-		t.Indicator,
-		t.BatchType,
-		t.ReferenceNumber,
-		t.TotalDebitTransactions,
-		t.TotalCreditTransactions,
-		t.TotalDebitAmount.StringFixedBank(2),
-		t.TotalCreditAmount.StringFixedBank(2),
-		spaces(40),
-	)
-	// Add final padding
-	fmt.Fprintf(w, "%s", padRight(tempStr, " ", 170))
+// Write BatchTrailer to io.Writer
+func (t *BatchTrailer) Write(w io.Writer) error {
+	return writeLine(w, batchTrailerLayout, t)
 }
 
-func (t *FileTrailer) Write(w io.Writer) {
-	tempStr := fmt.Sprintf(
-		"%d%08.8s%-35.35s%-6.1d%-6.1d%-16.16s%-16.16s%s",
-		t.recordType,
-		t.CustomerNumber,
-		t.CustomerName,
-		t.TotalDebitTransactions,
-		t.TotalCreditTransactions,
-		t.TotalDebitAmount.StringFixedBank(2),
-		t.TotalCreditAmount.StringFixedBank(2),
-		spaces(82),
-	)
-	// Add final padding
-	fmt.Fprintf(w, "%s", padRight(tempStr, " ", 170))
+// Write FileTrailer to io.Writer
+func (t *FileTrailer) Write(w io.Writer) error {
+	return writeLine(w, fileTrailerLayout, t)
 }
 
 // Write FileHeader to io.Writer
-func (h *FileHeader) Write(w io.Writer) {
-	tempStr := fmt.Sprintf(
-		"%d%08.8s%-35.35s%-20.20s%8.8s%8.8s%20.20s%s",
-		h.recordType,
-		h.CustomerNumber,
-		h.CustomerName,
-		h.RemitterName,
-		h.FileCreated.Format("20060102"),
-		h.ProcessingDate.Format("20060102"),
-		h.Description,
-		spaces(70),
-	)
-	// Add final padding
-	fmt.Fprintf(w, "%s", padRight(tempStr, " ", 170))
+func (h *FileHeader) Write(w io.Writer) error {
+	return writeLine(w, fileHeaderLayout, h)
 }
 
 // Write BatchHeader to io.Writer
-func (h *BatchHeader) Write(w io.Writer) {
-	tempStr := fmt.Sprintf(
-		"1%-7.7s%-9.9s%-35.35s%8.8s%16.16s%2.2s%s",
-		h.BSBNumber,
-		h.AccountNumber,
-		h.AccountName,
-		h.TransactionDate.Format("20060102"),
-		h.Amount.StringFixedBank(2),
-		h.Indicator,
-		spaces(92),
-	)
-	// Add final padding
-	fmt.Fprintf(w, "%s", padRight(tempStr, " ", 170))
+func (h *BatchHeader) Write(w io.Writer) error {
+	return writeLine(w, batchHeaderLayout, h)
+}
+
+// Write Record to io.Writer
+func (r *Record) Write(w io.Writer) error {
+	return writeLine(w, recordLayout, r)
 }
 
-func (r *Record) Write(w io.Writer) {
-	tempStr := fmt.Sprintf(
-		"2%7.7s%9.9s%-35.35s%8.8s%16.16s%2.2s%2.2s%-40.40s%-10.1d%-10.10s%-8.8s%s",
-		r.BSBNumber,
-		r.AccountNumber,
-		r.AccountName,
-		r.TransactionDate.Format("20060102"),
-		r.Amount.StringFixedBank(2),
-		r.Indicator,
-		r.TransactionCode,
-		r.Description,
-		r.ReferenceNumber,
-		r.SecondaryReferenceNumber,
-		r.ChequeNumber,
-		spaces(20),
-	)
-	// Add final padding
-	fmt.Fprintf(w, "%s", padRight(tempStr, " ", 168))
+// writeLine encodes v with layout l, without a line ending
+func writeLine(w io.Writer, l *Layout, v interface{}) error {
+	line, err := l.Encode(v)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, line)
+	return err
 }
@@ -2,11 +2,18 @@ package txn
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/17twenty/txn/bsb"
 	"github.com/shopspring/decimal"
 )
 
@@ -157,3 +164,1050 @@ func TestDemo(t *testing.T) {
 		t.Fatalf("Failure - expected 2 total debit tx but got %v\n", ff.FileTrailer.TotalDebitTransactions)
 	}
 }
+
+func TestStreamingReader(t *testing.T) {
+	f, err := os.Open("./Test_TXN_20170123.txn")
+
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	defer f.Close()
+
+	var headers, batches, records, trailers, fileTrailers int
+	txn := NewReader(f)
+	for {
+		e, err := txn.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected '", nil, "' but got", err)
+		}
+		switch v := e.(type) {
+		case FileHeader:
+			headers++
+		case BatchHeader:
+			batches++
+		case Record:
+			records++
+			if v.BSBNumber != "182-222" {
+				t.Fatalf("Failure - expected BSB 182-222 but got %v\n", v.BSBNumber)
+			}
+		case BatchTrailer:
+			trailers++
+		case FileTrailer:
+			fileTrailers++
+		}
+	}
+
+	if headers != 1 || batches != 1 || records != 10 || trailers != 1 || fileTrailers != 1 {
+		t.Fatalf("Failure - unexpected event counts %d %d %d %d %d\n", headers, batches, records, trailers, fileTrailers)
+	}
+	if len(txn.Batch) != 0 {
+		t.Fatalf("Failure - expected Next not to accumulate batches but got %v\n", len(txn.Batch))
+	}
+}
+
+func TestRecordBeforeBatch(t *testing.T) {
+	f, err := os.ReadFile("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	lines := strings.SplitAfter(string(f), "\n")
+
+	txn := NewReader(strings.NewReader(lines[0] + lines[2]))
+	if _, err := txn.ReadAll(); !errors.Is(err, ErrNoBatch) {
+		t.Fatal("Expected '", ErrNoBatch, "' but got", err)
+	}
+
+	// Reading on past a corrupt batch header keeps its records in its batch
+	lines[1] = lines[1][:52] + "2012XX31" + lines[1][60:]
+	txn = NewReader(strings.NewReader(strings.Join(lines, "")))
+	if _, err := txn.ReadAll(); !errors.Is(err, ErrBadBatchHeader) {
+		t.Fatal("Expected '", ErrBadBatchHeader, "' but got", err)
+	}
+	batch, err := txn.ReadAll()
+	if err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+	if len(batch) != 1 || len(batch[0].Records) != 10 || batch[0].BatchTrailer.ReferenceNumber != 1 || !batch[0].BatchHeader.TransactionDate.IsZero() {
+		t.Fatalf("Failure - expected the records in an empty batch but got %v\n", batch)
+	}
+}
+
+func TestStreamingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.FileHeader.CustomerNumber = "123456"
+	w.FileHeader.CustomerName = "ABC PTY LIMITED"
+	w.FileTrailer.CustomerNumber = w.FileHeader.CustomerNumber
+	w.FileTrailer.CustomerName = w.FileHeader.CustomerName
+
+	if err := w.WriteRecord(Record{}); err != ErrBatchNotOpen {
+		t.Fatal("Expected '", ErrBatchNotOpen, "' but got", err)
+	}
+
+	for b := 0; b < 2; b++ {
+		err := w.BeginBatch(BatchHeader{
+			BSBNumber:       "182-222",
+			AccountNumber:   "123456789",
+			AccountName:     "DEMO ACCOUNT NUMBER 2",
+			TransactionDate: time.Now(),
+			Indicator:       Credit,
+		})
+		if err != nil {
+			t.Fatal("error beginning batch", err)
+		}
+		for i := 0; i < 3; i++ {
+			r := Record{
+				AccountNumber:   "123456789",
+				BSBNumber:       "182-222",
+				AccountName:     "DEMO ACCOUNT NUMBER 2",
+				Indicator:       Credit,
+				TransactionCode: "50",
+				TransactionDate: time.Now(),
+				Description:     "STREAMED",
+				Amount:          decimal.NewFromFloat(10.50),
+			}
+			if i == 2 {
+				r.Indicator = Debit
+				r.TransactionCode = "13"
+			}
+			if err := w.WriteRecord(r); err != nil {
+				t.Fatal("error writing record", err)
+			}
+		}
+		if err := w.EndBatch(); err != nil {
+			t.Fatal("error ending batch", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("error closing writer", err)
+	}
+
+	ff := NewReader(&buf)
+	rr, err := ff.ReadAll()
+	if err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+	if len(rr) != 2 || len(rr[1].Records) != 3 {
+		t.Fatalf("Failure - expected 2 batches of 3 records but got %v\n", rr)
+	}
+	if rr[1].BatchTrailer.Amount.Cmp(decimal.NewFromFloat(10.50)) != 0 || rr[1].BatchTrailer.Indicator != Credit {
+		t.Fatalf("Failure - expected batch trailer 10.50 CR but got %v %v\n", rr[1].BatchTrailer.Amount, rr[1].BatchTrailer.Indicator)
+	}
+	if ff.FileTrailer.TotalCreditTransactions != 4 || ff.FileTrailer.TotalDebitTransactions != 2 {
+		t.Fatalf("Failure - expected 4 credits and 2 debits but got %v and %v\n", ff.FileTrailer.TotalCreditTransactions, ff.FileTrailer.TotalDebitTransactions)
+	}
+	if ff.FileTrailer.TotalCreditAmount.Cmp(decimal.NewFromFloat(42)) != 0 {
+		t.Fatalf("Failure - expected total credit amount 42 but got %v\n", ff.FileTrailer.TotalCreditAmount)
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	f, err := os.ReadFile("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	lines := strings.SplitAfter(string(f), "\n")
+	lines[4] = lines[4][:76] + "XX" + lines[4][78:]
+
+	txn := NewReader(strings.NewReader(strings.Join(lines, "")))
+	_, err = txn.ReadAll()
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatal("Expected a *ParseError but got", err)
+	}
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Fatal("Expected '", ErrInvalidRecord, "' but got", pe.Err)
+	}
+	expectedOffset := int64(len(lines[0]) + len(lines[1]) + len(lines[2]) + len(lines[3]))
+	if pe.Line != 5 || pe.Offset != expectedOffset || pe.Field != "Record.Indicator" || pe.Start != 76 || pe.End != 78 {
+		t.Fatalf("Failure - unexpected position %+v\n", pe)
+	}
+	if pe.Raw != strings.TrimRight(lines[4], "\r\n") {
+		t.Fatalf("Failure - expected raw line %q but got %q\n", lines[4], pe.Raw)
+	}
+}
+
+func TestStrictAndLenientFields(t *testing.T) {
+	f, err := os.ReadFile("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	lines := strings.SplitAfter(string(f), "\n")
+	// Corrupt the date and amount of the first record
+	lines[2] = lines[2][:52] + "2012XX02" + "         27X1.78" + lines[2][76:]
+	corrupt := strings.Join(lines, "")
+
+	_, err = NewReader(strings.NewReader(corrupt)).ReadAll()
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatal("Expected 2 field errors but got", err)
+	}
+	if errs[0].Field != "Record.TransactionDate" || errs[1].Field != "Record.Amount" || errs[1].Line != 3 {
+		t.Fatalf("Failure - unexpected field errors %v\n", errs)
+	}
+	if !errors.Is(err, ErrBadRecord) {
+		t.Fatal("Expected '", ErrBadRecord, "' but got", err)
+	}
+
+	// The zeroed amount no longer adds up so the totals check must be off too
+	txn := NewReader(strings.NewReader(corrupt))
+	txn.Lenient = true
+	txn.SkipVerify = true
+	batch, err := txn.ReadAll()
+	if err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+	if !batch[0].Records[0].Amount.IsZero() || !batch[0].Records[0].TransactionDate.IsZero() {
+		t.Fatalf("Failure - expected zero values but got %v\n", batch[0].Records[0])
+	}
+}
+
+func TestVerifyTotals(t *testing.T) {
+	f, err := os.ReadFile("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	lines := strings.SplitAfter(string(f), "\n")
+	// Drop a credit record so both trailers no longer add up
+	tampered := strings.Join(append(lines[:3:3], lines[4:]...), "")
+
+	_, err = NewReader(strings.NewReader(tampered)).ReadAll()
+	var te *TotalsError
+	if !errors.As(err, &te) || !errors.Is(err, ErrTotalsMismatch) {
+		t.Fatal("Expected a *TotalsError but got", err)
+	}
+	if te.RecordType != "BatchTrailer" || te.Batch != 0 || len(te.Mismatches) != 3 {
+		t.Fatalf("Failure - unexpected mismatches %+v\n", te)
+	}
+	if m := te.Mismatches[0]; m.Field != "BatchTrailer.TotalCreditTransactions" || m.Expected != "4" || m.Actual != "5" {
+		t.Fatalf("Failure - unexpected mismatch %+v\n", m)
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 12 {
+		t.Fatal("Expected the error on line 12 but got", err)
+	}
+
+	txn := NewReader(strings.NewReader(tampered))
+	txn.SkipVerify = true
+	if _, err := txn.ReadAll(); err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f, err := os.ReadFile("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	if report := Validate(bytes.NewReader(f)); len(report.Findings) != 0 {
+		t.Fatalf("Failure - expected no findings but got %v\n", report.Findings)
+	}
+
+	lines := strings.SplitAfter(string(f), "\n")
+	lines[4] = lines[4][:60] + "            0.00" + lines[4][76:]              // zero amount, throws totals out
+	lines[6] = lines[6][:76] + "XX" + lines[6][78:]                            // bad indicator
+	lines[7] = lines[7][:52] + "2012XX02" + "         27X1.78" + lines[7][76:] // bad date and amount
+	report := Validate(strings.NewReader(strings.Join(lines[:len(lines)-2], "")))
+
+	if report.Valid() {
+		t.Fatal("Expected the report to be invalid")
+	}
+	var fields []string
+	for _, f := range report.Errors() {
+		fields = append(fields, fmt.Sprintf("%d:%d:%s", f.Line, f.Record, f.Field))
+	}
+	expected := "7:4:Record.Indicator 8:5:Record.TransactionDate 8:5:Record.Amount 13:-1: 13:-1:"
+	if strings.Join(fields, " ") != expected {
+		t.Fatalf("Failure - expected errors %v but got %v\n", expected, fields)
+	}
+	if !errors.Is(report.Errors()[3].Err, ErrTotalsMismatch) || !errors.Is(report.Errors()[4].Err, ErrMissingFileTrailer) {
+		t.Fatalf("Failure - unexpected errors %v\n", report.Errors())
+	}
+	warnings := report.Warnings()
+	if len(warnings) != 1 || warnings[0].Line != 5 || warnings[0].Record != 2 || !errors.Is(warnings[0].Err, ErrZeroAmount) {
+		t.Fatalf("Failure - unexpected warnings %v\n", warnings)
+	}
+
+	// A corrupt batch header is the only problem reported, the records after
+	// it still belong to its batch
+	lines = strings.SplitAfter(string(f), "\n")
+	lines[1] = lines[1][:52] + "2012XX31" + lines[1][60:]
+	report = Validate(strings.NewReader(strings.Join(lines, "")))
+	if len(report.Findings) != 1 || report.Findings[0].Line != 2 || report.Findings[0].Batch != 0 || report.Findings[0].Field != "BatchHeader.TransactionDate" {
+		t.Fatalf("Failure - expected a single bad date but got %v\n", report.Findings)
+	}
+}
+
+func TestWriterValidate(t *testing.T) {
+	w := NewWriter(io.Discard)
+	w.Batch[0].Records = []Record{
+		{BSBNumber: "182-222", Indicator: Credit, Amount: decimal.NewFromFloat(1)},
+		{BSBNumber: "182222", Indicator: "Dr", Amount: decimal.NewFromFloat(1)},
+	}
+	w.Batch = append(w.Batch, NewBatch())
+
+	report := w.Validate()
+	errs, warnings := report.Errors(), report.Warnings()
+	if len(errs) != 2 || errs[0].Field != "Record.Indicator" || errs[1].Field != "Record.BSBNumber" || errs[1].Batch != 0 || errs[1].Record != 1 {
+		t.Fatalf("Failure - unexpected errors %v\n", errs)
+	}
+	if len(warnings) != 1 || warnings[0].Batch != 1 || !errors.Is(warnings[0].Err, ErrEmptyBatch) {
+		t.Fatalf("Failure - unexpected warnings %v\n", warnings)
+	}
+
+	// Everything Write stops at is reported, with the field when there is one
+	for _, tc := range []struct {
+		field  string
+		want   error
+		breaks func(w *Writer)
+	}{
+		{"", ErrInsufficientBatches, func(w *Writer) { w.Batch = nil }},
+		{"", ErrNoBalancing, func(w *Writer) { w.Dialect.BalancingLine = true }},
+		{"BatchHeader.Indicator", ErrUnknownIndicator, func(w *Writer) { w.Batch[0].BatchHeader.Indicator = "Dr" }},
+		{"BatchTrailer.BatchType", ErrUnknownBatchType, func(w *Writer) { w.Batch[0].BatchTrailer.BatchType = "xx" }},
+		{"Record.Indicator", ErrInvalidRecord, func(w *Writer) { w.Batch[0].Records[0].Indicator = "" }},
+		{"Record.TransactionCode", ErrWrongDirection, func(w *Writer) { w.Batch[0].Records[0].TransactionCode = "13" }},
+		{"Record.ReferenceNumber", ErrFieldOverflow, func(w *Writer) { w.Batch[0].Records[0].ReferenceNumber = 12345678901 }},
+		{"Record.Amount", ErrFieldOverflow, func(w *Writer) { w.Batch[0].Records[0].Amount = decimal.New(1, 15) }},
+		{"Record.BSBNumber", ErrInvalidRecord, func(w *Writer) { w.Balancing = &Balancing{BSBNumber: "182222", AccountNumber: "1"} }},
+		{"Record.AccountNumber", ErrBadAccountNumber, func(w *Writer) { w.Directory, _ = bsb.Parse(strings.NewReader("182-222,MBL,Sydney,,,NSW,2000,PEH\n")) }},
+	} {
+		w := NewWriter(io.Discard)
+		w.Batch[0].BatchHeader = BatchHeader{BSBNumber: "182-222", AccountNumber: "117867898"}
+		w.Batch[0].Records = []Record{{BSBNumber: "182-222", Indicator: Credit, TransactionCode: "50", Amount: decimal.NewFromFloat(10)}}
+		tc.breaks(w)
+		if err := w.Write(); !errors.Is(err, tc.want) {
+			t.Fatal("Expected '", tc.want, "' but got", err)
+		}
+		report := w.Validate()
+		errs := report.Errors()
+		if len(errs) != 1 || errs[0].Field != tc.field || !errors.Is(errs[0].Err, tc.want) {
+			t.Fatalf("Failure - expected %v on %q but got %v\n", tc.want, tc.field, errs)
+		}
+	}
+}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	f, err := os.ReadFile("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	txn := NewReader(bytes.NewReader(f))
+	if _, err := txn.ReadAll(); err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+	if txn.FileHeader.CustomerNumber != "00123456" || txn.FileHeader.CustomerName != "ABC PTY LIMITED" || txn.FileHeader.RemitterName != "MACQUARIE BANK" {
+		t.Fatalf("Failure - unexpected file header %+v\n", txn.FileHeader)
+	}
+
+	// Every line of the sample should encode back to exactly what was read
+	var buf bytes.Buffer
+	txn.FileHeader.Write(&buf)
+	buf.WriteByte('\n')
+	txn.Batch[0].BatchHeader.Write(&buf)
+	buf.WriteByte('\n')
+	for _, r := range txn.Batch[0].Records {
+		r.Write(&buf)
+		buf.WriteByte('\n')
+	}
+	txn.Batch[0].BatchTrailer.Write(&buf)
+	buf.WriteByte('\n')
+	txn.FileTrailer.Write(&buf)
+	buf.WriteByte('\n')
+
+	got, want := strings.Split(buf.String(), "\n"), strings.Split(string(f), "\n")
+	for i := range want {
+		// Blank or zero filled reference numbers of zero are written as 0
+		if i >= 2 && i < 12 && txn.Batch[0].Records[i-2].ReferenceNumber == 0 {
+			continue
+		}
+		if got[i] != want[i] {
+			t.Fatalf("Failure - line %d expected\n%q but got\n%q\n", i+1, want[i], got[i])
+		}
+	}
+
+	r := Record{BSBNumber: "182-222", Indicator: Credit, Amount: decimal.New(1, 20)}
+	if err := r.Write(io.Discard); !errors.Is(err, ErrFieldOverflow) {
+		t.Fatal("Expected '", ErrFieldOverflow, "' but got", err)
+	}
+
+	// The layouts handed out are copies, changing them can't break reading
+	// or writing
+	l := RecordLayout()
+	l.Fields[0].Length = 1
+	l.Fields = nil
+	if ll, _ := LayoutOf(Record{}); ll != nil {
+		ll.Fields[0].Start = 99
+	}
+	if RecordLayout().Fields[0].Length == 1 {
+		t.Fatal("Failure - changing a copy of RecordLayout changed the original")
+	}
+	buf.Reset()
+	txn.Batch[0].Records[0].Write(&buf)
+	if buf.String() != strings.TrimRight(want[2], "\r") {
+		t.Fatalf("Failure - expected\n%q but got\n%q\n", want[2], buf.String())
+	}
+}
+
+type bankRecord struct {
+	Record
+	BankReference string `txn:"pos=148,len=12"`
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	r := bankRecord{
+		Record: Record{
+			BSBNumber:       "182-222",
+			AccountNumber:   "123456789",
+			AccountName:     "DEMO ACCOUNT NUMBER 2",
+			TransactionDate: time.Date(2012, 7, 2, 0, 0, 0, 0, time.UTC),
+			Indicator:       Debit,
+			TransactionCode: "13",
+			Amount:          decimal.NewFromFloat(2721.78),
+			ReferenceNumber: 245397,
+		},
+		BankReference: "XYZ-1",
+	}
+
+	line, err := Marshal(&r)
+	if err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+	var plain bytes.Buffer
+	r.Record.Write(&plain)
+	if len(line) != 168 || string(line[:148]) != plain.String()[:148] || string(line[148:160]) != "XYZ-1       " {
+		t.Fatalf("Failure - unexpected line %q\n", line)
+	}
+
+	var back bankRecord
+	if err := Unmarshal(line, &back); err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+	if back.BankReference != "XYZ-1" || back.ReferenceNumber != 245397 || !back.Amount.Equal(r.Amount) || !back.TransactionDate.Equal(r.TransactionDate) {
+		t.Fatalf("Failure - expected %+v but got %+v\n", r, back)
+	}
+
+	var bad struct {
+		Kind int    `txn:"record=5,width=20"`
+		Name string `txn:"pos=1,len=10"`
+		Code string `txn:"pos=8,len=4"`
+	}
+	if _, err := Marshal(&bad); err == nil {
+		t.Fatal("Expected overlapping fields to be rejected")
+	}
+
+	var hidden struct {
+		Kind int    `txn:"record=5,width=20"`
+		name string `txn:"pos=1,len=10"`
+	}
+	if _, err := LayoutOf(&hidden); err == nil || !strings.Contains(err.Error(), "exported") {
+		t.Fatal("Expected an unexported column to be rejected but got", err)
+	}
+	if err := Unmarshal([]byte("5SAMPLE             "), &hidden); err == nil {
+		t.Fatal("Expected an unexported column to be rejected")
+	}
+}
+
+func TestDialect(t *testing.T) {
+	other := Dialect{
+		Name:             "Other",
+		LineWidth:        180,
+		RecordWidth:      180,
+		LineEnding:       "\r\n",
+		OmitBatchTotals:  true,
+		TransactionCodes: []string{"13", "50"},
+		DateFormat:       "02012006",
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Dialect = other
+	w.BeginBatch(BatchHeader{BSBNumber: "182-222", AccountNumber: "123456789", TransactionDate: time.Date(2012, 7, 31, 0, 0, 0, 0, time.UTC)})
+	r := Record{BSBNumber: "182-222", Indicator: Debit, TransactionCode: "39", Amount: decimal.NewFromFloat(1.76)}
+	if err := w.WriteRecord(r); !errors.Is(err, ErrInvalidRecord) {
+		t.Fatal("Expected '", ErrInvalidRecord, "' but got", err)
+	}
+	r.TransactionCode = "13"
+	if err := w.WriteRecord(r); err != nil {
+		t.Fatal("error writing record", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("error closing writer", err)
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\r\n")
+	if len(lines) != 6 || len(lines[2]) != 182 || lines[1][52:60] != "31072012" {
+		t.Fatalf("Failure - unexpected output %q\n", buf.String())
+	}
+
+	if _, err := NewReader(bytes.NewReader(buf.Bytes())).ReadAll(); !errors.Is(err, ErrBadFileHeader) {
+		t.Fatal("Expected '", ErrBadFileHeader, "' but got", err)
+	}
+	txn := NewReader(bytes.NewReader(buf.Bytes()))
+	txn.Dialect = other
+	batch, err := txn.ReadAll()
+	if err != nil {
+		t.Fatal("Expected '", nil, "' but got", err)
+	}
+	if !batch[0].BatchHeader.TransactionDate.Equal(time.Date(2012, 7, 31, 0, 0, 0, 0, time.UTC)) || len(batch[0].Records) != 1 {
+		t.Fatalf("Failure - unexpected batch %+v\n", batch[0])
+	}
+}
+
+func TestBalancing(t *testing.T) {
+	records := []Record{
+		{BSBNumber: "182-222", AccountNumber: "111111111", Indicator: Credit, TransactionCode: "50", Amount: decimal.NewFromFloat(100)},
+		{BSBNumber: "182-222", AccountNumber: "222222222", Indicator: Credit, TransactionCode: "50", Amount: decimal.NewFromFloat(50.25)},
+		{BSBNumber: "182-222", AccountNumber: "333333333", Indicator: Debit, TransactionCode: "13", Amount: decimal.NewFromFloat(20)},
+	}
+	funding := Balancing{BSBNumber: "182-512", AccountNumber: "999999999", AccountName: "FUNDING", Description: "BALANCE"}
+
+	for _, perFile := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Dialect.BalancingLine = true
+		w.Batch[0].BatchHeader.BSBNumber = "182-222"
+		w.Batch[0].Records = records
+		if err := w.Write(); err != ErrNoBalancing {
+			t.Fatal("Expected '", ErrNoBalancing, "' but got", err)
+		}
+
+		buf.Reset()
+		w = NewWriter(&buf)
+		w.Batch[0].BatchHeader.BSBNumber = "182-222"
+		w.Batch[0].Records = records
+		funding.PerFile = perFile
+		w.Balancing = &funding
+		if err := w.Write(); err != nil {
+			t.Fatal("error writing", err)
+		}
+		w.Flush()
+
+		txn := NewReader(&buf)
+		batch, err := txn.ReadAll()
+		if err != nil {
+			t.Fatal("Expected '", nil, "' but got", err)
+		}
+		last := batch[len(batch)-1]
+		balance := last.Records[len(last.Records)-1]
+		if balance.AccountNumber != "999999999" || balance.Indicator != Debit || balance.TransactionCode != "13" || !balance.Amount.Equal(decimal.NewFromFloat(130.25)) {
+			t.Fatalf("Failure - unexpected balancing record %+v\n", balance)
+		}
+		if perFile && (len(batch) != 2 || len(batch[0].Records) != 3) || !perFile && len(batch) != 1 {
+			t.Fatalf("Failure - unexpected batches for perFile %v: %+v\n", perFile, batch)
+		}
+		if txn.FileTrailer.TotalDebitTransactions != 2 || !txn.FileTrailer.TotalDebitAmount.Equal(txn.FileTrailer.TotalCreditAmount) {
+			t.Fatalf("Failure - expected a balanced file but got %+v\n", txn.FileTrailer)
+		}
+	}
+}
+
+func TestWriteIsRepeatable(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.FileHeader.CustomerNumber = "123456"
+	w.FileTrailer.CustomerNumber = "123456"
+	w.Batch[0].BatchHeader.BSBNumber = "182-222"
+	w.Batch[0].Records = []Record{
+		{BSBNumber: "182-222", Indicator: Credit, TransactionCode: "50", Amount: decimal.NewFromFloat(10)},
+		{BSBNumber: "182-222", Indicator: Debit, TransactionCode: "13", Amount: decimal.NewFromFloat(25)},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := w.Write(); err != nil {
+			t.Fatal("error writing", err)
+		}
+	}
+	w.Flush()
+
+	out := buf.String()
+	if out[:len(out)/2] != out[len(out)/2:] {
+		t.Fatalf("Failure - expected both writes to match but got\n%s\n", out)
+	}
+	if !w.FileTrailer.TotalDebitAmount.IsZero() || w.FileTrailer.TotalDebitTransactions != 0 {
+		t.Fatalf("Failure - expected FileTrailer to be left alone but got %+v\n", w.FileTrailer)
+	}
+
+	batchTrailers, fileTrailer := w.Trailers()
+	if len(batchTrailers) != 1 || !batchTrailers[0].Amount.Equal(decimal.NewFromFloat(15)) || batchTrailers[0].Indicator != Debit {
+		t.Fatalf("Failure - unexpected batch trailers %+v\n", batchTrailers)
+	}
+	if fileTrailer.CustomerNumber != "123456" || fileTrailer.TotalDebitTransactions != 1 || !fileTrailer.TotalCreditAmount.Equal(decimal.NewFromFloat(10)) {
+		t.Fatalf("Failure - unexpected file trailer %+v\n", fileTrailer)
+	}
+}
+
+func TestFixedClock(t *testing.T) {
+	sydney := time.FixedZone("AEDT", 11*60*60)
+	// Still the 22nd in UTC but already the 23rd in Sydney
+	clock := FixedClock(time.Date(2017, 1, 22, 14, 0, 0, 0, time.UTC))
+
+	write := func() []byte {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Clock = clock
+		w.Location = sydney
+		w.Batch[0].BatchHeader.BSBNumber = "182-222"
+		w.Batch[0].Records = []Record{
+			{BSBNumber: "182-222", Indicator: Credit, TransactionCode: "50", Amount: decimal.NewFromFloat(10)},
+		}
+		if err := w.Write(); err != nil {
+			t.Fatal("error writing", err)
+		}
+		w.Flush()
+		return buf.Bytes()
+	}
+
+	first, second := write(), write()
+	if !bytes.Equal(first, second) {
+		t.Fatalf("Failure - expected identical output but got\n%s\n%s\n", first, second)
+	}
+	lines := strings.Split(string(first), "\n")
+	if lines[0][64:80] != "2017012320170123" || lines[1][52:60] != "20170123" || lines[3][52:60] != "20170123" {
+		t.Fatalf("Failure - expected Sydney dates but got\n%s\n", first)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	input, err := os.ReadFile("Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("error opening file", err)
+	}
+	lines := strings.SplitAfter(string(input), "\n")
+	lines[3] = lines[3][:60] + "ABCDEFGHIJKLMNOP" + lines[3][76:]
+
+	var buf bytes.Buffer
+	r := NewReader(strings.NewReader(strings.Join(lines, "")))
+	r.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if _, err := r.ReadAll(); err == nil {
+		t.Fatal("Expected an error but got", err)
+	}
+	logged := buf.String()
+	for _, want := range []string{
+		"line=1 record_type=FileHeader batch=-1",
+		"line=2 record_type=BatchHeader batch=0",
+		"level=WARN msg=\"txn: bad line\" line=4 record_type=Record batch=0",
+	} {
+		if !strings.Contains(logged, want) {
+			t.Fatalf("Failure - expected %q in\n%s", want, logged)
+		}
+	}
+
+	// Nothing is logged, nor does it panic, without a Logger
+	r = NewReader(bytes.NewReader(input))
+	if _, err := r.ReadAll(); err != nil {
+		t.Fatal("error reading", err)
+	}
+}
+
+func TestAccountValidation(t *testing.T) {
+	dir, err := bsb.Parse(strings.NewReader("182-222,MBL,Sydney,1 Shelley Street,Sydney,NSW,2000,PEH\n182-100,MBL,Sydney,Closed,Sydney,NSW,2000,\n"))
+	if err != nil {
+		t.Fatal("error parsing directory", err)
+	}
+	r := Record{BSBNumber: "182-222", AccountNumber: "117867898", Indicator: Debit, TransactionCode: "13"}
+	if err := r.Validate(dir); err != nil {
+		t.Fatal("Expected a valid record but got", err)
+	}
+
+	for _, tc := range []struct {
+		bsb, account string
+		want         error
+	}{
+		{"182222", "117867898", ErrBadBSB},
+		{"999-222", "117867898", ErrUnknownBSB},
+		{"182-999", "117867898", ErrUnknownBSB},
+		{"182-100", "117867898", ErrClosedBSB},
+		{"182-222", "1234567890", ErrBadAccountNumber},
+		{"182-222", "", ErrBadAccountNumber},
+		{"182-222", "11786-789", ErrBadAccountNumber},
+	} {
+		r.BSBNumber, r.AccountNumber = tc.bsb, tc.account
+		if err := r.Validate(dir); !errors.Is(err, tc.want) {
+			t.Fatal("Expected '", tc.want, "' but got", err)
+		}
+	}
+
+	// Without a directory only the format is checked, Cuscal and credit
+	// union BSBs included
+	r.AccountNumber = "117867898"
+	for _, b := range []string{"182-999", "704-235", "802-985", "814-282"} {
+		r.BSBNumber = b
+		if err := r.Validate(nil); err != nil {
+			t.Fatal("Expected a valid record but got", err)
+		}
+	}
+	r.BSBNumber = "814282"
+	if err := r.Validate(nil); !errors.Is(err, ErrBadBSB) {
+		t.Fatal("Expected '", ErrBadBSB, "' but got", err)
+	}
+
+	// A directory knows of BSBs whose prefix bsb.Banks doesn't
+	local, err := bsb.Parse(strings.NewReader("BSB,Mnemonic,Name,Address,Suburb,State,Postcode,Flags\n814-282,CUA,Sample Branch,1 Sample Street,Brisbane,QLD,4000,PEH\n"))
+	if err != nil {
+		t.Fatal("error parsing directory", err)
+	}
+	r.BSBNumber = "814-282"
+	if err := r.Validate(local); err != nil {
+		t.Fatal("Expected a valid record but got", err)
+	}
+	h := BatchHeader{BSBNumber: "182-100", AccountNumber: "117867898"}
+	if err := h.Validate(dir); !errors.Is(err, ErrClosedBSB) {
+		t.Fatal("Expected '", ErrClosedBSB, "' but got", err)
+	}
+	if b, ok := h.Branch(dir); !ok || b.Mnemonic != "MBL" {
+		t.Fatalf("Failure - expected the MBL branch but got %+v", b)
+	}
+
+	// The Writer rejects closed BSBs before writing anything
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Directory = dir
+	w.Batch[0].BatchHeader = BatchHeader{BSBNumber: "182-222", AccountNumber: "117867898"}
+	w.Batch[0].Records = []Record{
+		{BSBNumber: "182-100", AccountNumber: "1234", Indicator: Credit, TransactionCode: "50", Amount: decimal.NewFromFloat(10)},
+	}
+	if report := w.Validate(); report.Valid() || report.Errors()[0].Field != "Record.BSBNumber" {
+		t.Fatalf("Failure - expected a closed BSB finding but got %v", report.Findings)
+	}
+	err = w.Write()
+	if !errors.Is(err, ErrClosedBSB) {
+		t.Fatal("Expected '", ErrClosedBSB, "' but got", err)
+	}
+	// Nothing was parsed so it's reported as a validation error
+	var fe *FieldError
+	var pe *ParseError
+	if !errors.As(err, &fe) || fe.Field != "Record.BSBNumber" || errors.As(err, &pe) {
+		t.Fatal("Expected a *FieldError for Record.BSBNumber but got", err)
+	}
+}
+
+func TestTransactionCodes(t *testing.T) {
+	tc, ok := LookupTransactionCode("53")
+	if !ok || tc.Indicator != Credit || tc.Description != "Pay" {
+		t.Fatalf("Failure - expected the Pay credit code but got %+v", tc)
+	}
+
+	r := Record{BSBNumber: "182-222", Indicator: Debit, TransactionCode: "50"}
+	if r.IsValid() {
+		t.Fatal("Expected a debit carrying a credit code to be invalid")
+	}
+	if err := r.Validate(nil); !errors.Is(err, ErrWrongDirection) {
+		t.Fatal("Expected '", ErrWrongDirection, "' but got", err)
+	}
+
+	// Bank specific codes can be registered
+	r.TransactionCode = "98"
+	if !r.IsValid() {
+		t.Fatal("Expected an unregistered code to be valid")
+	}
+	t.Cleanup(func() {
+		codesMu.Lock()
+		defer codesMu.Unlock()
+		delete(transactionCodes, "98")
+	})
+	RegisterTransactionCode(TransactionCode{Code: "98", Description: "Bank specific credit", Indicator: Credit})
+	if r.IsValid() {
+		t.Fatal("Expected a registered credit code to be invalid on a debit")
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.FillTransactionCodes = true
+	w.Batch[0].Records = []Record{
+		{BSBNumber: "182-222", Indicator: Debit, Amount: decimal.NewFromFloat(10)},
+		{BSBNumber: "182-222", Indicator: Credit, Amount: decimal.NewFromFloat(10)},
+	}
+	if err := w.Write(); err != nil {
+		t.Fatal("error writing", err)
+	}
+	w.Flush()
+	lines := strings.Split(buf.String(), "\n")
+	if lines[2][76:80] != "DR13" || lines[3][76:80] != "CR50" {
+		t.Fatalf("Failure - expected default codes but got\n%s", buf.String())
+	}
+}
+
+func TestIndicator(t *testing.T) {
+	if _, err := ParseIndicator("Dr"); !errors.Is(err, ErrUnknownIndicator) || err.Error() != `txn: Indicator must be DR or CR, got "Dr"` {
+		t.Fatal("Expected '", ErrUnknownIndicator, "' but got", err)
+	}
+	if _, err := ParseBatchType("PS"); !errors.Is(err, ErrUnknownBatchType) {
+		t.Fatal("Expected '", ErrUnknownBatchType, "' but got", err)
+	}
+	if Debit.Sign() != -1 || Credit.Sign() != 1 || Indicator("").Sign() != 0 || Debit.Opposite() != Credit {
+		t.Fatal("Failure - unexpected signs")
+	}
+
+	b, err := json.Marshal(BatchTrailer{Indicator: Debit, BatchType: BatchTXN})
+	if err != nil || !strings.Contains(string(b), `"Indicator":"DR","BatchType":"ST"`) {
+		t.Fatal("Expected DR and ST but got", string(b), err)
+	}
+	var bt BatchTrailer
+	if err = json.Unmarshal([]byte(`{"Indicator":"Dr"}`), &bt); !errors.Is(err, ErrUnknownIndicator) {
+		t.Fatal("Expected '", ErrUnknownIndicator, "' but got", err)
+	}
+	if _, err = json.Marshal(Record{Indicator: "Dr"}); !errors.Is(err, ErrUnknownIndicator) {
+		t.Fatal("Expected '", ErrUnknownIndicator, "' but got", err)
+	}
+	if err = json.Unmarshal([]byte(`{"Indicator":""}`), &bt); err != nil || bt.Indicator != "" {
+		t.Fatal("Expected a blank indicator but got", bt.Indicator, err)
+	}
+
+	// Unknown values fail loudly when read too
+	f, err := os.ReadFile("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	lines := strings.SplitAfter(string(f), "\n")
+	if err = bt.Read(lines[12][:78] + "XX" + lines[12][80:]); !errors.Is(err, ErrUnknownBatchType) {
+		t.Fatal("Expected '", ErrUnknownBatchType, "' but got", err)
+	}
+	if err = bt.Read(lines[12]); err != nil || bt.BatchType != BatchTXN || bt.Indicator != Debit {
+		t.Fatal("Expected a DR ST trailer but got", bt.Indicator, bt.BatchType, err)
+	}
+
+	// and when written
+	var fe *FieldError
+	w := NewWriter(io.Discard)
+	w.Batch[0].BatchHeader.Indicator = "Dr"
+	if err = w.Write(); !errors.Is(err, ErrUnknownIndicator) || !errors.As(err, &fe) || fe.Field != "BatchHeader.Indicator" {
+		t.Fatal("Expected '", ErrUnknownIndicator, "' but got", err)
+	}
+	w = NewWriter(io.Discard)
+	w.Batch[0].BatchTrailer.BatchType = "xx"
+	if err = w.Write(); !errors.Is(err, ErrUnknownBatchType) || !errors.As(err, &fe) || fe.Field != "BatchTrailer.BatchType" {
+		t.Fatal("Expected '", ErrUnknownBatchType, "' but got", err)
+	}
+	w = NewWriter(io.Discard)
+	if err = w.BeginBatch(BatchHeader{Indicator: "cr"}); !errors.Is(err, ErrUnknownIndicator) {
+		t.Fatal("Expected '", ErrUnknownIndicator, "' but got", err)
+	}
+}
+
+func TestTotalsAndNet(t *testing.T) {
+	f, err := os.Open("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	defer f.Close()
+	file, err := NewReader(f).ReadFile()
+	if err != nil {
+		t.Fatal("error reading", err)
+	}
+
+	r := file.Batches[0].Records[0]
+	if !r.SignedAmount().Equal(decimal.RequireFromString("-2721.78")) {
+		t.Fatal("Expected '-2721.78' but got", r.SignedAmount())
+	}
+
+	b := file.Batches[0]
+	debits, credits := b.Totals()
+	bt := b.BatchTrailer
+	if debits.Count != bt.TotalDebitTransactions || !debits.Amount.Equal(bt.TotalDebitAmount) ||
+		credits.Count != bt.TotalCreditTransactions || !credits.Amount.Equal(bt.TotalCreditAmount) {
+		t.Fatalf("Failure - totals %v %v disagree with the trailer %+v", debits, credits, bt)
+	}
+	if !b.Net().Equal(bt.Amount.Mul(decimal.NewFromInt(int64(bt.Indicator.Sign())))) {
+		t.Fatal("Expected '-1211.18' but got", b.Net())
+	}
+	if opening, closing := b.Balances(); !opening.Equal(decimal.RequireFromString("1637.50")) || !closing.Equal(decimal.RequireFromString("426.32")) {
+		t.Fatal("Expected '1637.50' and '426.32' but got", opening, closing)
+	}
+
+	debits, credits = file.Totals()
+	ft := file.FileTrailer
+	if debits.Count != ft.TotalDebitTransactions || !credits.Amount.Equal(ft.TotalCreditAmount) {
+		t.Fatalf("Failure - totals %v %v disagree with the trailer %+v", debits, credits, ft)
+	}
+	if !file.Net().Equal(b.Net()) {
+		t.Fatal("Expected '", b.Net(), "' but got", file.Net())
+	}
+
+	// The Writer's trailers agree with Totals
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Batch = file.Batches
+	if err = w.Write(); err != nil {
+		t.Fatal("error writing", err)
+	}
+	trailers, _ := w.Trailers()
+	debits, _ = b.Totals()
+	if trailers[0].TotalDebitTransactions != debits.Count || !trailers[0].TotalDebitAmount.Equal(debits.Amount) {
+		t.Fatalf("Failure - expected %v but got %+v", debits, trailers[0])
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	f, err := os.Open("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	defer f.Close()
+	file, err := NewReader(f).ReadFile()
+	if err != nil {
+		t.Fatal("error reading", err)
+	}
+	// A second batch whose header date and trailer details are all left
+	// zero, as is the processing date of the file
+	second := file.Batches[0]
+	second.Records = append([]Record(nil), second.Records[:3]...)
+	second.BatchHeader.TransactionDate = time.Time{}
+	second.BatchTrailer = BatchTrailer{}
+	file.Batches = append(file.Batches, second)
+	file.FileHeader.ProcessingDate = time.Time{}
+	file.SetTrailers()
+	first, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal("error marshalling", err)
+	}
+	for _, want := range []string{
+		`"file_created":"2012-08-01"`,
+		`"amount":"1210.00","indicator":"CR","transaction_code":"50"`,
+		`"batch_type":"ST","reference_number":1`,
+		`"total_credit_amount":"10648.96"`,
+	} {
+		if !strings.Contains(string(first), want) {
+			t.Fatalf("Failure - expected %s in\n%s", want, first)
+		}
+	}
+
+	// JSON -> Writer -> TXN -> Reader -> JSON
+	var decoded File
+	if err = json.Unmarshal(first, &decoded); err != nil {
+		t.Fatal("error unmarshalling", err)
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err = w.WriteFile(&decoded); err != nil {
+		t.Fatal("error writing", err)
+	}
+	w.Flush()
+	file, err = NewReader(&buf).ReadFile()
+	if err != nil {
+		t.Fatal("error reading back", err)
+	}
+	again, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal("error marshalling", err)
+	}
+	if !bytes.Equal(first, again) {
+		t.Fatalf("Failure - expected a lossless round trip but got\n%s\n%s", first, again)
+	}
+	if bt := file.Batches[1].BatchTrailer; bt.ReferenceNumber != 0 || bt.BatchType != "" || !bt.TransactionDate.IsZero() {
+		t.Fatalf("Failure - expected the second trailer as given but got %+v", bt)
+	}
+	if !file.Batches[1].BatchHeader.TransactionDate.IsZero() || !file.FileHeader.ProcessingDate.IsZero() {
+		t.Fatalf("Failure - expected the zero header dates as given but got %+v %+v", file.FileHeader, file.Batches[1].BatchHeader)
+	}
+
+	if err = json.Unmarshal([]byte(`{"header":{"file_created":"01/08/2012"}}`), &decoded); err == nil {
+		t.Fatal("Expected an error but got", err)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	f, err := os.Open("./Test_TXN_20170123.txn")
+	if err != nil {
+		t.Fatal("Couldn't find local test file")
+	}
+	defer f.Close()
+	batches, err := NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal("error reading", err)
+	}
+
+	var buf bytes.Buffer
+	c := CSV{}
+	if err = c.Encode(&buf, batches); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "batch_bsb,batch_account_number,batch_account_name,bsb,account_number,account_name,transaction_date,amount,indicator,transaction_code,description,reference_number,secondary_reference_number,cheque_number" {
+		t.Fatal("Expected the default header but got", lines[0])
+	}
+	if lines[1] != "182-222,117867898,DEMO ACCOUNT NUMBER 2,182-222,117867898,DEMO ACCOUNT NUMBER 2,2012-07-02,2721.78,DR,13,DDR GL481         Tower Australia,245397,," {
+		t.Fatal("Unexpected first row", lines[1])
+	}
+
+	decoded, err := c.Decode(&buf)
+	if err != nil {
+		t.Fatal("error decoding", err)
+	}
+	if len(decoded) != 1 || len(decoded[0].Records) != 10 || decoded[0].BatchHeader.AccountNumber != "117867898" {
+		t.Fatalf("Failure - expected 1 batch of 10 records but got %+v", decoded)
+	}
+	for i := range decoded[0].Records {
+		want, got := batches[0].Records[i], decoded[0].Records[i]
+		if !want.Amount.Equal(got.Amount) || want.Description != got.Description || !want.TransactionDate.Equal(got.TransactionDate) {
+			t.Fatalf("Failure - expected %+v but got %+v", want, got)
+		}
+	}
+
+	// A spreadsheet with its own headings, signed amounts and local dates
+	c = CSV{
+		Columns: []CSVColumn{
+			{"BatchBSBNumber", "From BSB"},
+			{"BatchAccountNumber", "From Account"},
+			{"BSBNumber", "BSB"},
+			{"AccountNumber", "Account"},
+			{"TransactionDate", "Date"},
+			{"Amount", "Amount"},
+			{"Description", "Reference"},
+		},
+		DateFormat:    "02/01/2006",
+		SignedAmounts: true,
+	}
+	sheet := "Reference,Date,BSB,Account,Amount,From BSB,From Account\n" +
+		"Rent,01/08/2012,182-222,12345,\"$1,200.00\",182-222,117867898\n" +
+		"Refund,02/08/2012,182-222,12345,-15.50,182-222,117867898\n" +
+		"Wages,03/08/2012,182222,12345,10,182-222,117867898\n" +
+		"Bonus,31/02/2012,182-222,12345,ten,182-512,117867898\n" +
+		"Super,04/08/2012,182-222,12345,20,182-512,117867898\n"
+	decoded, err = c.Decode(strings.NewReader(sheet))
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatal("Expected 3 row errors but got", err)
+	}
+	if errs[0].Line != 4 || errs[0].Field != "Record.BSBNumber" || errs[1].Line != 5 || errs[2].Line != 5 || errs[2].Field != "Record.Amount" {
+		t.Fatalf("Failure - unexpected row errors %v", errs)
+	}
+	if len(decoded) != 2 || len(decoded[0].Records) != 2 || decoded[1].BatchHeader.BSBNumber != "182-512" {
+		t.Fatalf("Failure - expected 2 batches but got %+v", decoded)
+	}
+	r := decoded[0].Records[0]
+	if !r.Amount.Equal(decimal.NewFromInt(1200)) || r.Indicator != Credit || r.TransactionDate.Day() != 1 || decoded[0].Records[1].Indicator != Debit {
+		t.Fatalf("Failure - unexpected record %+v", r)
+	}
+
+	if _, err = c.Decode(strings.NewReader("BSB,Amount\n")); !errors.Is(err, ErrMissingColumn) {
+		t.Fatal("Expected '", ErrMissingColumn, "' but got", err)
+	}
+
+	// Undated records round trip with another delimiter, which errors
+	// quote rows with too
+	c = CSV{Comma: ';'}
+	undated := []Batch{{BatchHeader: batches[0].BatchHeader, Records: []Record{batches[0].Records[0]}}}
+	undated[0].Records[0].TransactionDate = time.Time{}
+	buf.Reset()
+	if err = c.Encode(&buf, undated); err != nil {
+		t.Fatal("error encoding", err)
+	}
+	decoded, err = c.Decode(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal("error decoding", err)
+	}
+	if !decoded[0].Records[0].TransactionDate.IsZero() {
+		t.Fatal("Expected a zero date but got", decoded[0].Records[0].TransactionDate)
+	}
+	bad := strings.Replace(buf.String(), ";2721.78;", ";ten;", 1)
+	_, err = c.Decode(strings.NewReader(bad))
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Raw != strings.Split(bad, "\n")[1] {
+		t.Fatal("Expected the row as read but got", err)
+	}
+}
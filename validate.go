@@ -0,0 +1,325 @@
+package txn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/17twenty/txn/bsb"
+)
+
+// Severity ranks how serious a Finding is
+type Severity int
+
+const (
+	// SeverityError findings would cause the file to be rejected
+	SeverityError Severity = iota
+	// SeverityWarning findings are suspicious but still readable
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Finding is a single problem found while validating a file or Writer
+type Finding struct {
+	Severity Severity
+	Line     int    // 1-based line number, 0 when validating a Writer
+	Batch    int    // 0-based batch index, -1 when not within a batch
+	Record   int    // 0-based record index within the batch, -1 when not a record
+	Field    string // e.g. Record.Amount, empty when not field specific
+	Err      error
+}
+
+func (f Finding) String() string {
+	var loc []string
+	if f.Line > 0 {
+		loc = append(loc, fmt.Sprintf("line %d", f.Line))
+	}
+	if f.Batch >= 0 {
+		loc = append(loc, fmt.Sprintf("batch %d", f.Batch))
+	}
+	if f.Record >= 0 {
+		loc = append(loc, fmt.Sprintf("record %d", f.Record))
+	}
+	if len(loc) == 0 {
+		return fmt.Sprintf("%s: %v", f.Severity, f.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", f.Severity, strings.Join(loc, ", "), f.Err)
+}
+
+// ValidationReport collects every Finding from a single validation pass
+type ValidationReport struct {
+	Findings []Finding
+}
+
+// Valid reports whether there were no SeverityError findings
+func (r *ValidationReport) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns the SeverityError findings
+func (r *ValidationReport) Errors() []Finding {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the SeverityWarning findings
+func (r *ValidationReport) Warnings() []Finding {
+	return r.filter(SeverityWarning)
+}
+
+func (r *ValidationReport) filter(s Severity) []Finding {
+	var found []Finding
+	for _, f := range r.Findings {
+		if f.Severity == s {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+func (r *ValidationReport) add(s Severity, line, batch, record int, field string, err error) {
+	r.Findings = append(r.Findings, Finding{
+		Severity: s,
+		Line:     line,
+		Batch:    batch,
+		Record:   record,
+		Field:    field,
+		Err:      err,
+	})
+}
+
+// checkRecord adds warnings for records that are valid but suspicious
+func (r *ValidationReport) checkRecord(line, batch, record int, rec *Record) {
+	if rec.Amount.IsZero() {
+		r.add(SeverityWarning, line, batch, record, "Record.Amount", ErrZeroAmount)
+	}
+}
+
+// Validate reads a whole TXN file from in and reports every structural,
+// field level and totals problem found rather than stopping at the first.
+func Validate(in io.Reader) ValidationReport {
+	var (
+		report               ValidationReport
+		rd                   = NewReader(in)
+		batch, record        = -1, -1
+		inBatch              bool
+		sawHeader, sawFooter bool
+	)
+
+	for {
+		e, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if sawFooter {
+			report.add(SeverityError, rd.line, -1, -1, "", ErrTrailingData)
+		}
+		if err != nil {
+			errs := parseErrors(err)
+			if errs == nil {
+				// Not a decoding problem, nothing more can be read
+				report.add(SeverityError, rd.line, batch, record, "", err)
+				break
+			}
+			// Keep track of where we are even though the line was bad
+			switch errs[0].RecordType {
+			case "BatchHeader":
+				batch, record, inBatch = batch+1, -1, true
+			case "Record":
+				record++
+			case "BatchTrailer":
+				inBatch = false
+			case "FileTrailer":
+				sawFooter = true
+			}
+			rec := -1
+			if errs[0].RecordType == "Record" {
+				rec = record
+			}
+			for _, pe := range errs {
+				report.add(SeverityError, pe.Line, batch, rec, pe.Field, pe)
+			}
+			continue
+		}
+
+		switch v := e.(type) {
+		case FileHeader:
+			if rd.line != 1 {
+				report.add(SeverityError, rd.line, -1, -1, "", ErrMissingFileHeader)
+			}
+			sawHeader = true
+		case BatchHeader:
+			if inBatch {
+				report.add(SeverityError, rd.line, batch, -1, "", ErrMissingBatchTrailer)
+			}
+			batch, record, inBatch = batch+1, -1, true
+		case Record:
+			record++
+			report.checkRecord(rd.line, batch, record, &v)
+		case BatchTrailer:
+			if record < 0 {
+				report.add(SeverityWarning, rd.line, batch, -1, "", ErrEmptyBatch)
+			}
+			inBatch = false
+		case FileTrailer:
+			if inBatch {
+				report.add(SeverityError, rd.line, batch, -1, "", ErrMissingBatchTrailer)
+			}
+			sawFooter = true
+		}
+	}
+
+	if !sawHeader {
+		report.add(SeverityError, 1, -1, -1, "", ErrMissingFileHeader)
+	}
+	if !sawFooter {
+		report.add(SeverityError, rd.line, -1, -1, "", ErrMissingFileTrailer)
+	}
+	if batch < 0 {
+		report.add(SeverityWarning, 0, -1, -1, "", ErrInsufficientBatches)
+	}
+	return report
+}
+
+// Validate checks the populated Batches of w without writing anything,
+// reporting every problem that Write would stop at and anything suspicious.
+// Each line is put through the same checks as Write, but carrying on past
+// the ones that fail.
+func (w *Writer) Validate() ValidationReport {
+	var report ValidationReport
+	if len(w.Batch) < 1 {
+		report.add(SeverityError, 0, -1, -1, "", ErrInsufficientBatches)
+	}
+	report.addErr(-1, -1, w.checkBalancing())
+
+	// A copy of w writes to nowhere, leaving w as it was
+	dry := *w
+	dry.wr = bufio.NewWriter(io.Discard)
+	dry.Logger = nil
+	dry.batches, dry.inBatch, dry.asGiven = 0, false, false
+	report.addErr(-1, -1, dry.writeFileHeader())
+	for k, batch := range w.Batch {
+		if len(batch.Records) == 0 {
+			report.add(SeverityWarning, 0, k, -1, "", ErrEmptyBatch)
+		}
+		h := batch.BatchHeader
+		report.addErr(k, -1, dry.writeBatchHeader(&h))
+		dry.open(h)
+		dry.trailer = batch.BatchTrailer
+		for i, r := range batch.Records {
+			report.addErr(k, i, dry.WriteRecord(r))
+			report.checkRecord(0, k, i, &r)
+		}
+		report.addErr(k, -1, dry.EndBatch())
+	}
+	report.addErr(len(w.Batch), -1, dry.writeFileBalancing())
+	report.addErr(-1, -1, dry.writeFileTrailer())
+	return report
+}
+
+// addErr adds an error finding for each field err is about, or for err as
+// a whole, if it isn't nil
+func (r *ValidationReport) addErr(batch, record int, err error) {
+	var (
+		errs FieldErrors
+		fe   *FieldError
+	)
+	switch {
+	case err == nil:
+	case errors.As(err, &errs):
+		for _, fe := range errs {
+			r.add(SeverityError, 0, batch, record, fe.Field, fe)
+		}
+	case errors.As(err, &fe):
+		r.add(SeverityError, 0, batch, record, fe.Field, err)
+	default:
+		r.add(SeverityError, 0, batch, record, "", err)
+	}
+}
+
+// parseErrors unpacks the *ParseError values within err, if any
+func parseErrors(err error) ParseErrors {
+	var errs ParseErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return ParseErrors{pe}
+	}
+	return nil
+}
+
+// Directory resolves a BSB to its branch, such as a *bsb.Directory
+type Directory interface {
+	Lookup(bsb string) (bsb.Branch, bool)
+}
+
+// Branch looks the BSB of the batch up in dir, e.g. to show the branch name
+// alongside it
+func (h *BatchHeader) Branch(dir Directory) (bsb.Branch, bool) {
+	return dir.Lookup(h.BSBNumber)
+}
+
+// Branch looks the BSB of the record up in dir
+func (r *Record) Branch(dir Directory) (bsb.Branch, bool) {
+	return dir.Lookup(r.BSBNumber)
+}
+
+// Validate checks the BSB and account number of the batch header. The BSB
+// must also be open in dir unless dir is nil.
+func (h *BatchHeader) Validate(dir Directory) error {
+	return checkAccount(batchHeaderLayout, h.BSBNumber, h.AccountNumber, dir).asError()
+}
+
+// Validate checks the BSB and account number of the batch trailer. The BSB
+// must also be open in dir unless dir is nil.
+func (t *BatchTrailer) Validate(dir Directory) error {
+	return checkAccount(batchTrailerLayout, t.BSBNumber, t.AccountNumber, dir).asError()
+}
+
+// Validate checks everything IsValid does along with the account number.
+// The BSB must also be open in dir unless dir is nil.
+func (r *Record) Validate(dir Directory) error {
+	return r.validate(new(Dialect), dir).asError()
+}
+
+func (r *Record) validate(d *Dialect, dir Directory) FieldErrors {
+	var errs FieldErrors
+	for _, fe := range r.invalidFields(d) {
+		// A malformed BSB is reported by checkAccount
+		if fe.Field != "Record.BSBNumber" {
+			errs = append(errs, fe)
+		}
+	}
+	return append(errs, checkAccount(recordLayout, r.BSBNumber, r.AccountNumber, dir)...)
+}
+
+// checkAccount returns an error for the BSB and account number fields of
+// layout if they can't be paid to. The directory, when given, is the
+// authority on which BSBs exist. Without one only the format of the BSB is
+// checked, as bsb.Banks only knows the common prefixes and every state
+// digit is in use.
+func checkAccount(l *Layout, bsbNumber, accountNumber string, dir Directory) FieldErrors {
+	var errs FieldErrors
+	if !bsbNumberRegEx.MatchString(bsbNumber) {
+		errs = append(errs, l.invalid("BSBNumber", ErrBadBSB))
+	} else if dir != nil {
+		if b, ok := dir.Lookup(bsbNumber); !ok {
+			errs = append(errs, l.invalid("BSBNumber", ErrUnknownBSB))
+		} else if b.Closed {
+			errs = append(errs, l.invalid("BSBNumber", ErrClosedBSB))
+		}
+	}
+	if !accountNumberRegEx.MatchString(accountNumber) {
+		errs = append(errs, l.invalid("AccountNumber", ErrBadAccountNumber))
+	}
+	return errs
+}
@@ -0,0 +1,120 @@
+package txn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Mismatch is a single trailer field that disagrees with the records read
+type Mismatch struct {
+	Field    string // e.g. BatchTrailer.TotalDebitAmount
+	Expected string // value computed from the records
+	Actual   string // value found in the trailer
+}
+
+// TotalsError is returned by the Reader when a BatchTrailer or FileTrailer
+// doesn't match the records that precede it, e.g. in a truncated or
+// tampered file. It wraps ErrTotalsMismatch.
+type TotalsError struct {
+	RecordType string // BatchTrailer or FileTrailer
+	Batch      int    // 0-based index of the batch, -1 for the FileTrailer
+	Mismatches []Mismatch
+}
+
+func (e *TotalsError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrTotalsMismatch.Error())
+	if e.Batch >= 0 {
+		fmt.Fprintf(&b, " in batch %d", e.Batch)
+	}
+	for _, m := range e.Mismatches {
+		fmt.Fprintf(&b, "; %s expected %s got %s", m.Field, m.Expected, m.Actual)
+	}
+	return b.String()
+}
+
+func (e *TotalsError) Unwrap() error {
+	return ErrTotalsMismatch
+}
+
+// tally accumulates debit and credit counts and sums over records
+type tally struct {
+	debits       int
+	credits      int
+	debitAmount  decimal.Decimal
+	creditAmount decimal.Decimal
+}
+
+func (t *tally) add(r Record) {
+	switch r.Indicator {
+	case Debit:
+		t.debits++
+		t.debitAmount = t.debitAmount.Add(r.Amount)
+	case Credit:
+		t.credits++
+		t.creditAmount = t.creditAmount.Add(r.Amount)
+	}
+}
+
+// summaries returns the totals as exported Summary values
+func (t *tally) summaries() (debits, credits Summary) {
+	return Summary{t.debits, t.debitAmount}, Summary{t.credits, t.creditAmount}
+}
+
+// net returns the absolute net amount and whether it's a debit or credit
+func (t *tally) net() (decimal.Decimal, Indicator) {
+	amount := t.creditAmount.Sub(t.debitAmount)
+	if amount.Sign() < 0 {
+		return amount.Abs(), Debit
+	}
+	return amount, Credit
+}
+
+// compare appends a Mismatch for each total that disagrees with the trailer
+func (t *tally) compare(recordType string, debits, credits int, debitAmount, creditAmount decimal.Decimal) []Mismatch {
+	var m []Mismatch
+	if t.debits != debits {
+		m = append(m, Mismatch{recordType + ".TotalDebitTransactions", strconv.Itoa(t.debits), strconv.Itoa(debits)})
+	}
+	if t.credits != credits {
+		m = append(m, Mismatch{recordType + ".TotalCreditTransactions", strconv.Itoa(t.credits), strconv.Itoa(credits)})
+	}
+	if !t.debitAmount.Equal(debitAmount) {
+		m = append(m, Mismatch{recordType + ".TotalDebitAmount", t.debitAmount.StringFixedBank(2), debitAmount.StringFixedBank(2)})
+	}
+	if !t.creditAmount.Equal(creditAmount) {
+		m = append(m, Mismatch{recordType + ".TotalCreditAmount", t.creditAmount.StringFixedBank(2), creditAmount.StringFixedBank(2)})
+	}
+	return m
+}
+
+// verifyBatch checks a BatchTrailer against the records of its batch
+func (t *tally) verifyBatch(batch int, bt *BatchTrailer) error {
+	m := t.compare("BatchTrailer", bt.TotalDebitTransactions, bt.TotalCreditTransactions, bt.TotalDebitAmount, bt.TotalCreditAmount)
+
+	amount, indicator := t.net()
+	if !amount.Equal(bt.Amount) {
+		m = append(m, Mismatch{"BatchTrailer.Amount", amount.StringFixedBank(2), bt.Amount.StringFixedBank(2)})
+	}
+	// A zero net amount may be marked either way
+	if indicator != bt.Indicator && !amount.IsZero() {
+		m = append(m, Mismatch{"BatchTrailer.Indicator", indicator.String(), bt.Indicator.String()})
+	}
+
+	if len(m) > 0 {
+		return &TotalsError{RecordType: "BatchTrailer", Batch: batch, Mismatches: m}
+	}
+	return nil
+}
+
+// verifyFile checks a FileTrailer against every record in the file
+func (t *tally) verifyFile(ft *FileTrailer) error {
+	m := t.compare("FileTrailer", ft.TotalDebitTransactions, ft.TotalCreditTransactions, ft.TotalDebitAmount, ft.TotalCreditAmount)
+	if len(m) > 0 {
+		return &TotalsError{RecordType: "FileTrailer", Batch: -1, Mismatches: m}
+	}
+	return nil
+}
@@ -4,10 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"time"
-
-	"github.com/shopspring/decimal"
 )
 
 // Writer implements buffering for an io.Writer object.
@@ -23,24 +21,100 @@ type Writer struct {
 	// CRLFLineEndings allows you to toggle whether to use Windows/DOS style
 	// line endings vs the default unix style
 	CRLFLineEndings bool
-	FileHeader      *FileHeader
-	FileTrailer     *FileTrailer
-	Batch           []Batch
-	wr              *bufio.Writer
+	// Dialect of the file, Macquarie's when left as the zero value
+	Dialect Dialect
+	// Clock stamps FileCreated, ProcessingDate and TransactionDate when they
+	// are left zero and dates the batch trailers, time.Now if nil. Dates are
+	// written in Location, the local time zone if nil.
+	Clock    Clock
+	Location *time.Location
+	// Logger receives structured debug and warning logs, nil discards them
+	Logger *slog.Logger
+	// FillTransactionCodes gives records written without a TransactionCode
+	// the DefaultTransactionCode of their Indicator
+	FillTransactionCodes bool
+	// Directory, when set, turns on full validation of the BSBs and account
+	// numbers written, rejecting unknown or closed BSBs. See bsb.Open.
+	Directory Directory
+	// Balancing, when set, adds a record offsetting the net debit/credit of
+	// each batch or of the whole file
+	Balancing  *Balancing
+	FileHeader *FileHeader
+	// FileTrailer supplies the customer details of the trailer, the totals
+	// are always computed from the records written
+	FileTrailer *FileTrailer
+	Batch       []Batch
+	wr          *bufio.Writer
+
+	// Streaming state for BeginBatch/WriteRecord/EndBatch/Close
+	batch       BatchHeader
+	trailer     BatchTrailer // caller's trailer when called by Write
+	asGiven     bool         // dates and trailer details written unchanged, by WriteFile
+	batches     int
+	inBatch     bool
+	batchTotals tally
+	fileTotals  tally
+
+	// Trailers as emitted for the last file written
+	batchTrailers []BatchTrailer
+	fileTrailer   FileTrailer
+}
+
+// Balancing describes the self-balancing record appended by a Writer. It
+// posts the net of the debits and credits against a funding account so they
+// cancel out, and is counted in the trailer totals like any other record.
+type Balancing struct {
+	// PerFile writes a single balancing record for the whole file, in a
+	// batch of its own for the funding account after the other batches.
+	// Otherwise each batch gets its own balancing record.
+	PerFile       bool
+	BSBNumber     string
+	AccountNumber string
+	AccountName   string
+	Description   string
+	// DebitCode and CreditCode are the transaction codes of a balancing
+	// debit or credit, 13 and 50 if empty
+	DebitCode  string
+	CreditCode string
+}
+
+// record returns the Record offsetting totals, false if they already net to zero
+func (b *Balancing) record(totals *tally, date time.Time) (Record, bool) {
+	amount, indicator := totals.net()
+	if amount.IsZero() {
+		return Record{}, false
+	}
+	r := Record{
+		BSBNumber:       b.BSBNumber,
+		AccountNumber:   b.AccountNumber,
+		AccountName:     b.AccountName,
+		TransactionDate: date,
+		Amount:          amount,
+		Indicator:       Debit,
+		TransactionCode: b.DebitCode,
+		Description:     b.Description,
+	}
+	if indicator == Debit {
+		r.Indicator, r.TransactionCode = Credit, b.CreditCode
+	}
+	if r.TransactionCode == "" {
+		r.TransactionCode = DefaultTransactionCode(r.Indicator)
+	}
+	return r, true
 }
 
 // NewWriter returns a new Writer whose buffer has the default size.
+// The dates of the FileHeader and first batch are left zero to be stamped
+// by the Writer's Clock when written.
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{
 		wr: bufio.NewWriter(w),
 		FileHeader: &FileHeader{
-			recordType:     0,
-			FileCreated:    time.Now(),
-			ProcessingDate: time.Now(),
-			Description:    "ACCOUNT TRANSACTIONS",
+			recordType:  0,
+			Description: "ACCOUNT TRANSACTIONS",
 		},
 		Batch: []Batch{
-			NewBatch(),
+			newBatch(time.Time{}),
 		},
 
 		FileTrailer: &FileTrailer{
@@ -49,109 +123,327 @@ func NewWriter(w io.Writer) *Writer {
 	}
 }
 
-// NewBatch ..
+// NewBatch returns an empty Batch dated now
 func NewBatch() Batch {
+	return newBatch(time.Now())
+}
+
+// NewBatch returns an empty Batch dated by the Writer's Clock
+func (w *Writer) NewBatch() Batch {
+	return newBatch(w.now())
+}
+
+func newBatch(now time.Time) Batch {
 	return Batch{
 		BatchHeader: BatchHeader{
 			recordType:      1,
-			TransactionDate: time.Now(),
+			TransactionDate: now,
 		},
 		BatchTrailer: BatchTrailer{
 			recordType:      7,
-			TransactionDate: time.Now(),
-			BatchType:       BatchPAY,
+			TransactionDate: now,
 		},
 	}
 }
 
 // Write writes the entire file containing an array of Batches, each one with 1 or more records
 // It returns an error if something is wrong with the batches/records.
+// The totals of each BatchTrailer are computed but its date, BatchType and
+// ReferenceNumber are kept when set.
 func (w *Writer) Write() (err error) {
 	if len(w.Batch) < 1 {
 		return ErrInsufficientBatches
 	}
-
-	w.FileHeader.Write(w.wr)
-	if w.CRLFLineEndings {
-		w.wr.WriteByte('\r')
+	if err = w.checkBalancing(); err != nil {
+		return err
 	}
-	w.wr.WriteByte('\n')
 
-	for k, batch := range w.Batch {
-		batch.BatchHeader.Write(w.wr)
-		if w.CRLFLineEndings {
-			w.wr.WriteByte('\r')
+	// Start afresh even if an earlier Write failed part way
+	w.batches, w.inBatch = 0, false
+	for _, batch := range w.Batch {
+		if err = w.BeginBatch(batch.BatchHeader); err != nil {
+			return err
 		}
-		var batchDebitCounter int
-		var batchCreditCounter int
-		var batchDebitTx decimal.Decimal
-		var batchCreditTx decimal.Decimal
-		w.wr.WriteByte('\n')
-
+		w.trailer = batch.BatchTrailer
 		for i, r := range batch.Records {
-			// Validation spin...
-			if !r.IsValid() {
-				return fmt.Errorf("%v (record %d)", ErrInvalidRecord, i)
-			}
-			if !w.OmitBatchTotals {
-				switch r.Indicator {
-				case Debit:
-					w.FileTrailer.TotalDebitAmount = w.FileTrailer.TotalDebitAmount.Add(r.Amount)
-					w.FileTrailer.TotalDebitTransactions++
-					batchDebitCounter++
-					batchDebitTx = batchDebitTx.Add(r.Amount)
-				case Credit:
-					w.FileTrailer.TotalCreditAmount = w.FileTrailer.TotalCreditAmount.Add(r.Amount)
-					w.FileTrailer.TotalCreditTransactions++
-					batchCreditCounter++
-					batchCreditTx = batchCreditTx.Add(r.Amount)
-
-				default:
-					log.Println("Unknown transaction type", r.Indicator, "in record", i)
-				}
+			if err = w.WriteRecord(r); err != nil {
+				return fmt.Errorf("%w (record %d)", err, i)
 			}
+		}
+		if err = w.EndBatch(); err != nil {
+			return err
+		}
+	}
 
-			r.Write(w.wr)
+	if err = w.writeFileBalancing(); err != nil {
+		return err
+	}
+	return w.writeFileTrailer()
+}
 
-			if w.CRLFLineEndings {
-				w.wr.WriteByte('\r')
-			}
-			w.wr.WriteByte('\n')
+// BeginBatch starts a new batch by writing its BatchHeader. The FileHeader is
+// written first if this is the first batch. Records are then added with
+// WriteRecord and the batch is finished with EndBatch.
+func (w *Writer) BeginBatch(h BatchHeader) error {
+	if w.inBatch {
+		return ErrBatchOpen
+	}
+	if w.batches == 0 {
+		if err := w.checkBalancing(); err != nil {
+			return err
 		}
-		batchAmount := batchCreditTx.Sub(batchDebitTx)
-		indicator := "CR"
-		if batchAmount.Sign() < 0 {
-			indicator = "DR"
+		if err := w.writeFileHeader(); err != nil {
+			return err
 		}
-		batch.BatchTrailer.BSBNumber = batch.BatchHeader.BSBNumber
-		batch.BatchTrailer.AccountNumber = batch.BatchHeader.AccountNumber
-		batch.BatchTrailer.AccountName = batch.BatchHeader.AccountName
-		batch.BatchTrailer.TransactionDate = time.Now()
-		batch.BatchTrailer.Amount = batchAmount.Abs()
-		batch.BatchTrailer.Indicator = indicator
-		batch.BatchTrailer.BatchType = BatchTXN
-		batch.BatchTrailer.ReferenceNumber = k
-		batch.BatchTrailer.TotalDebitTransactions = batchDebitCounter
-		batch.BatchTrailer.TotalCreditTransactions = batchCreditCounter
-		batch.BatchTrailer.TotalDebitAmount = batchDebitTx
-		batch.BatchTrailer.TotalCreditAmount = batchCreditTx
-
-		batch.BatchTrailer.Write(w.wr)
-		if w.CRLFLineEndings {
-			w.wr.WriteByte('\r')
+	}
+	if err := w.writeBatchHeader(&h); err != nil {
+		return err
+	}
+	w.open(h)
+	return nil
+}
+
+// checkBalancing fails if the dialect needs a balancing record but the
+// Writer has no Balancing to make it from
+func (w *Writer) checkBalancing() error {
+	if w.Dialect.BalancingLine && w.Balancing == nil {
+		return ErrNoBalancing
+	}
+	return nil
+}
+
+// writeFileHeader writes the FileHeader, starting the totals of the file
+func (w *Writer) writeFileHeader() error {
+	fh := *w.FileHeader
+	if fh.FileCreated.IsZero() && !w.asGiven {
+		fh.FileCreated = w.now()
+	}
+	if fh.ProcessingDate.IsZero() && !w.asGiven {
+		fh.ProcessingDate = w.now()
+	}
+	w.fileTotals = tally{}
+	w.batchTrailers = nil
+	return w.writeLine(fileHeaderLayout, &fh)
+}
+
+// writeBatchHeader validates and writes h, dating it if need be
+func (w *Writer) writeBatchHeader(h *BatchHeader) error {
+	if w.Directory != nil {
+		if err := h.Validate(w.Directory); err != nil {
+			logger(w.Logger).Warn("txn: invalid batch header", "batch", w.batches, "bsb", h.BSBNumber, "err", err)
+			return err
 		}
-		w.wr.WriteByte('\n')
 	}
+	if h.TransactionDate.IsZero() && !w.asGiven {
+		h.TransactionDate = w.now()
+	}
+	return w.writeLine(batchHeaderLayout, h)
+}
+
+// open makes h the current batch for WriteRecord and EndBatch
+func (w *Writer) open(h BatchHeader) {
+	w.batch = h
+	w.trailer = BatchTrailer{}
+	w.batches++
+	w.inBatch = true
+	w.batchTotals = tally{}
+}
 
+// WriteRecord validates and writes a single record into the current batch,
+// updating the running batch and file totals.
+func (w *Writer) WriteRecord(r Record) error {
+	if !w.inBatch {
+		return ErrBatchNotOpen
+	}
+	if w.FillTransactionCodes && r.TransactionCode == "" {
+		r.TransactionCode = DefaultTransactionCode(r.Indicator)
+	}
+	// Validation spin...
+	if err := w.invalidRecord(&r).asError(); err != nil {
+		logger(w.Logger).Warn("txn: invalid record", "batch", w.batches-1, "record", w.batchTotals.debits+w.batchTotals.credits, "indicator", r.Indicator, "bsb", r.BSBNumber, "transaction_code", r.TransactionCode, "err", err)
+		return err
+	}
+	// Only what is written counts towards the totals
+	if err := w.writeLine(recordLayout, &r); err != nil {
+		return err
+	}
+	w.batchTotals.add(r)
+	w.fileTotals.add(r)
+	return nil
+}
+
+// invalidRecord returns an error for each field of r that stops it being
+// written, checking the account against the Directory when there is one
+func (w *Writer) invalidRecord(r *Record) FieldErrors {
+	if w.Directory != nil {
+		return r.validate(&w.Dialect, w.Directory)
+	}
+	return r.invalidFields(&w.Dialect)
+}
+
+// EndBatch finishes the current batch by writing a BatchTrailer holding the
+// totals of the records written since BeginBatch.
+func (w *Writer) EndBatch() error {
+	if !w.inBatch {
+		return ErrBatchNotOpen
+	}
+	if w.Balancing != nil && !w.Balancing.PerFile {
+		if r, ok := w.Balancing.record(&w.batchTotals, w.batch.TransactionDate); ok {
+			if err := w.WriteRecord(r); err != nil {
+				return fmt.Errorf("balancing record: %w", err)
+			}
+		}
+	}
+
+	totals := w.batchTotals
+	if w.omitTotals() {
+		totals = tally{}
+	}
+	batchAmount, indicator := totals.net()
+	trailer := BatchTrailer{
+		recordType:              7,
+		BSBNumber:               w.batch.BSBNumber,
+		AccountNumber:           w.batch.AccountNumber,
+		AccountName:             w.batch.AccountName,
+		TransactionDate:         w.trailer.TransactionDate,
+		Amount:                  batchAmount,
+		Indicator:               indicator,
+		BatchType:               w.trailer.BatchType,
+		ReferenceNumber:         w.trailer.ReferenceNumber,
+		TotalDebitTransactions:  totals.debits,
+		TotalCreditTransactions: totals.credits,
+		TotalDebitAmount:        totals.debitAmount,
+		TotalCreditAmount:       totals.creditAmount,
+	}
+
+	if trailer.TransactionDate.IsZero() && !w.asGiven {
+		trailer.TransactionDate = w.now()
+	}
+	if trailer.BatchType == "" && !w.asGiven {
+		trailer.BatchType = BatchTXN
+	}
+	if trailer.ReferenceNumber == 0 && !w.asGiven {
+		trailer.ReferenceNumber = w.batches - 1
+	}
+
+	w.inBatch = false
+	w.batchTrailers = append(w.batchTrailers, trailer)
+	logger(w.Logger).Debug("txn: wrote batch", "batch", w.batches-1, "debits", trailer.TotalDebitTransactions, "credits", trailer.TotalCreditTransactions, "amount", trailer.Amount.StringFixedBank(2), "indicator", trailer.Indicator)
+	return w.writeLine(batchTrailerLayout, &trailer)
+}
+
+// WriteFile writes f using its FileHeader, Batches and the customer details
+// of its FileTrailer, as Write does. Unlike Write the dates of the headers
+// and the date, BatchType and ReferenceNumber of each BatchTrailer are
+// written exactly as given, even when zero, so a File read in is written
+// back unchanged.
+func (w *Writer) WriteFile(f *File) error {
+	fh, ft := f.FileHeader, f.FileTrailer
+	w.FileHeader, w.FileTrailer, w.Batch = &fh, &ft, f.Batches
+	w.asGiven = true
+	defer func() { w.asGiven = false }()
+	return w.Write()
+}
+
+// Close ends any open batch, writes the FileTrailer and flushes the
+// underlying io.Writer. It is the streaming counterpart to Write.
+func (w *Writer) Close() error {
+	if w.inBatch {
+		if err := w.EndBatch(); err != nil {
+			return err
+		}
+	}
+	if w.batches == 0 {
+		return ErrInsufficientBatches
+	}
+
+	if err := w.writeFileBalancing(); err != nil {
+		return err
+	}
+	if err := w.writeFileTrailer(); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeFileBalancing adds a batch for the funding account holding a single
+// record that balances the whole file, if asked for
+func (w *Writer) writeFileBalancing() error {
+	if w.Balancing == nil || !w.Balancing.PerFile {
+		return nil
+	}
+	r, ok := w.Balancing.record(&w.fileTotals, w.now())
+	if !ok {
+		return nil
+	}
+	err := w.BeginBatch(BatchHeader{
+		recordType:      1,
+		BSBNumber:       r.BSBNumber,
+		AccountNumber:   r.AccountNumber,
+		AccountName:     r.AccountName,
+		TransactionDate: r.TransactionDate,
+		Amount:          r.Amount,
+		Indicator:       r.Indicator,
+	})
+	if err != nil {
+		return err
+	}
+	if err = w.WriteRecord(r); err != nil {
+		return fmt.Errorf("balancing record: %w", err)
+	}
+	return w.EndBatch()
+}
+
+// writeFileTrailer writes the totals of the file using w.FileTrailer for
+// the customer details, leaving the Writer ready to start another file
+func (w *Writer) writeFileTrailer() error {
 	// Last part is to get net trailer amount
-	// Some banks require a balancing line at the bottom
-	// We're going to omit it unless told otherwise
-	w.FileTrailer.Write(w.wr)
+	// Some banks require a balancing line at the bottom, see Balancing
+	totals := w.fileTotals
+	if w.omitTotals() {
+		totals = tally{}
+	}
+	w.fileTrailer = FileTrailer{
+		recordType:              9,
+		CustomerNumber:          w.FileTrailer.CustomerNumber,
+		CustomerName:            w.FileTrailer.CustomerName,
+		TotalDebitTransactions:  totals.debits,
+		TotalCreditTransactions: totals.credits,
+		TotalDebitAmount:        totals.debitAmount,
+		TotalCreditAmount:       totals.creditAmount,
+	}
+	logger(w.Logger).Debug("txn: wrote file", "batches", w.batches, "debits", totals.debits, "credits", totals.credits)
+	w.batches = 0
+	return w.writeLine(fileTrailerLayout, &w.fileTrailer)
+}
+
+// Trailers returns the BatchTrailers and FileTrailer exactly as emitted for
+// the last file written, whether by Write or by the streaming methods.
+// Neither w.Batch nor w.FileTrailer are modified when writing.
+func (w *Writer) Trailers() ([]BatchTrailer, FileTrailer) {
+	return w.batchTrailers, w.fileTrailer
+}
+
+// omitTotals reports whether trailers are written without totals
+func (w *Writer) omitTotals() bool {
+	return w.OmitBatchTotals || w.Dialect.OmitBatchTotals
+}
+
+// writeLine encodes v using the dialect's version of base followed by the
+// line ending
+func (w *Writer) writeLine(base *Layout, v interface{}) error {
+	line, err := w.Dialect.Layout(base).Encode(v)
+	if err != nil {
+		return err
+	}
+	w.wr.WriteString(line)
 	if w.CRLFLineEndings {
-		w.wr.WriteByte('\r')
+		_, err = w.wr.WriteString("\r\n")
+	} else {
+		_, err = w.wr.WriteString(w.Dialect.lineEnding())
 	}
-	w.wr.WriteByte('\n')
-	return nil
+	return err
 }
 
 // Flush can be called to ensure all data has been written